@@ -3,30 +3,46 @@
 
 -- Create extensions
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+-- Tenants (organizations) - every coach, calendar, appointment, API key and subscription belongs to one
+CREATE TABLE tenants (
+    id VARCHAR PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+    name VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
 
 -- Coaches table - stores coach information and performance metrics
 CREATE TABLE coaches (
     id VARCHAR PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+    tenant_id VARCHAR NOT NULL DEFAULT 'default' REFERENCES tenants(id),
     name VARCHAR NOT NULL,
-    email VARCHAR UNIQUE NOT NULL,
+    email VARCHAR NOT NULL,
     score FLOAT DEFAULT 0.0,
+    score_locked BOOLEAN NOT NULL DEFAULT false, -- manual score; skipped by the recompute job
     max_daily_appointments INTEGER DEFAULT 10,
     working_hours_start TIME DEFAULT '09:00:00',
     working_hours_end TIME DEFAULT '17:00:00',
     timezone VARCHAR DEFAULT 'America/New_York',
+    active BOOLEAN NOT NULL DEFAULT true, -- inactive coaches keep their history but get no new appointments
     created_at TIMESTAMP DEFAULT NOW(),
-    updated_at TIMESTAMP
+    updated_at TIMESTAMP,
+    UNIQUE (tenant_id, email)
 );
 
 -- Calendars table - different calendar types/configurations
 CREATE TABLE calendars (
     id VARCHAR PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+    tenant_id VARCHAR NOT NULL DEFAULT 'default' REFERENCES tenants(id),
     name VARCHAR NOT NULL,
     calendar_type VARCHAR DEFAULT 'standard',
+    capacity INTEGER NOT NULL DEFAULT 1 CHECK (capacity >= 1), -- seats per session; above 1 only for 'group' calendars
     slot_duration INTEGER DEFAULT 30, -- in minutes
     slot_interval INTEGER DEFAULT 15, -- interval between slot start times - one slot starts at 10AM, the next at 10:15, but 10AM being booked takes out 10:15 for the same coach as well since duration is 30 minutes
+    active BOOLEAN NOT NULL DEFAULT true, -- inactive calendars keep their appointments but take no new bookings
     created_at TIMESTAMP DEFAULT NOW(),
-    updated_at TIMESTAMP
+    updated_at TIMESTAMP,
+    CHECK (calendar_type = 'group' OR capacity = 1)
 );
 
 -- Coach-Calendar relationship mapping - who is on what calendar? This decides teams for each calendar
@@ -48,12 +64,40 @@ CREATE TABLE coach_slots (
     UNIQUE(coach_id, start_time)
 );
 
+-- Coach time off (vacations, partial-day blocks) recorded by us rather than the external calendar
+CREATE TABLE coach_time_off (
+    id VARCHAR PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+    tenant_id VARCHAR NOT NULL DEFAULT 'default' REFERENCES tenants(id),
+    coach_id VARCHAR NOT NULL REFERENCES coaches(id) ON DELETE CASCADE,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    all_day BOOLEAN NOT NULL DEFAULT false, -- whole days in the coach's timezone
+    reason TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (end_time > start_time)
+);
+
+-- Recurring appointment series; each occurrence is a coach_appointments row
+CREATE TABLE appointment_series (
+    id VARCHAR PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+    tenant_id VARCHAR NOT NULL DEFAULT 'default' REFERENCES tenants(id),
+    calendar_id VARCHAR REFERENCES calendars(id),
+    contact_id VARCHAR NOT NULL,
+    rrule VARCHAR NOT NULL, -- e.g. 'FREQ=WEEKLY;INTERVAL=2;COUNT=6'
+    timezone VARCHAR NOT NULL DEFAULT 'UTC', -- occurrences keep their local time across DST
+    first_start_time TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
 -- Appointments table
 CREATE TABLE coach_appointments (
     id VARCHAR PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+    tenant_id VARCHAR NOT NULL DEFAULT 'default' REFERENCES tenants(id),
     coach_id VARCHAR REFERENCES coaches(id),
     calendar_id VARCHAR REFERENCES calendars(id),
     contact_id VARCHAR NOT NULL, --the customer's id, don't worry about their db storage right now
+    contact_email VARCHAR, -- where reminders are sent
+    contact_name VARCHAR,
     title VARCHAR,
     start_time TIMESTAMPTZ NOT NULL,
     end_time TIMESTAMPTZ NOT NULL,
@@ -65,6 +109,16 @@ CREATE TABLE coach_appointments (
     updated_at TIMESTAMPTZ,
     confirmed_at TIMESTAMPTZ,
     cancelled_at TIMESTAMPTZ,
+    needs_reassignment BOOLEAN NOT NULL DEFAULT false, -- coach became unavailable after booking
+    reassignment_reason VARCHAR CHECK (reassignment_reason IN ('time_off', 'coach_inactive', 'slot_blocked')),
+    reassignment_attempted_at TIMESTAMPTZ,
+    reassignment_error TEXT, -- why the last reassignment attempt could not place the appointment
+    previous_coach_id VARCHAR REFERENCES coaches(id), -- set when the appointment is moved to another coach
+    outcome_recorded_at TIMESTAMPTZ, -- when status became 'completed' or 'no_show'
+    outcome_source VARCHAR CHECK (outcome_source IN ('auto', 'manual')), -- the OutcomeRecorder or the outcome endpoint
+    series_id VARCHAR REFERENCES appointment_series(id), -- set for occurrences of a recurring booking
+    series_index INTEGER, -- 0 for the first occurrence
+    seat INTEGER NOT NULL DEFAULT 1, -- seat taken in the coach's session; 1 unless the calendar is a group one
 
     -- Integration fields
     external_calendar_id VARCHAR,
@@ -72,16 +126,34 @@ CREATE TABLE coach_appointments (
     webhook_status VARCHAR DEFAULT 'pending',
     webhook_attempts INTEGER DEFAULT 0,
     webhook_last_attempt TIMESTAMPTZ,
+
+    -- CRM sync ('pending' until the CRM acknowledges crm_sync_event)
+    crm_sync_status VARCHAR CHECK (crm_sync_status IN ('pending', 'synced', 'failed')),
+    crm_sync_event VARCHAR, -- 'created', 'cancelled' or 'confirmed'
+    crm_sync_attempts INTEGER DEFAULT 0,
+    crm_last_attempt TIMESTAMPTZ,
+    crm_last_error TEXT,
+    crm_synced_at TIMESTAMPTZ,
+    cancellation_reason VARCHAR,
+
+    -- External calendar sync ('pending' until the Calendar API has applied calendar_sync_event;
+    -- NULL on group session seats that don't hold the session's block)
+    calendar_sync_status VARCHAR CHECK (calendar_sync_status IN ('pending', 'synced', 'failed')),
+    calendar_sync_event VARCHAR, -- 'block' or 'release'
+    calendar_sync_attempts INTEGER DEFAULT 0,
+    calendar_last_attempt TIMESTAMPTZ,
+    calendar_last_error TEXT,
     
     -- Metadata
     source VARCHAR, -- 'api', 'webhook', 'manual'
     notes TEXT,
-    metadata JSONB,
-    
-    -- Prevent double booking
-    CONSTRAINT no_double_booking UNIQUE (coach_id, start_time)
+    metadata JSONB
 );
 
+-- Prevent double booking: one scheduled appointment per coach, start time and
+-- seat. Group sessions hold one seat per attendee; cancelling frees the seat.
+CREATE UNIQUE INDEX no_double_booking ON coach_appointments (coach_id, start_time, seat) WHERE status = 'scheduled';
+
 -- Webhook events log
 CREATE TABLE webhook_events (
     id VARCHAR PRIMARY KEY DEFAULT uuid_generate_v4()::text,
@@ -99,14 +171,61 @@ CREATE TABLE webhook_events (
 -- API keys for authentication
 CREATE TABLE api_keys (
     id VARCHAR PRIMARY KEY DEFAULT uuid_generate_v4()::text,
-    key_hash VARCHAR UNIQUE NOT NULL,
+    tenant_id VARCHAR NOT NULL DEFAULT 'default' REFERENCES tenants(id),
+    key_hash VARCHAR UNIQUE NOT NULL, -- hex HMAC-SHA256 of the key, keyed with API_KEY_HASH_SECRET
     name VARCHAR NOT NULL,
+    key_type VARCHAR DEFAULT 'test',
+    permissions JSONB DEFAULT '{"read": true, "write": true, "delete": false, "admin": false}',
     active BOOLEAN DEFAULT true,
     rate_limit INTEGER DEFAULT 100,
+    key_prefix VARCHAR, -- first characters of the key, to tell keys apart in listings
+    expires_at TIMESTAMPTZ, -- set on rotation; the key keeps working until then
+    rotated_from VARCHAR REFERENCES api_keys(id),
     created_at TIMESTAMP DEFAULT NOW(),
     last_used_at TIMESTAMP
 );
 
+-- Coach score changes, manual or recomputed from appointment outcomes, for trend reporting
+CREATE TABLE coach_score_history (
+    id VARCHAR PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+    tenant_id VARCHAR NOT NULL DEFAULT 'default' REFERENCES tenants(id),
+    coach_id VARCHAR NOT NULL REFERENCES coaches(id) ON DELETE CASCADE,
+    score FLOAT NOT NULL,
+    previous_score FLOAT,
+    source VARCHAR NOT NULL CHECK (source IN ('manual', 'import', 'recompute')),
+    reason TEXT,
+    details JSONB, -- outcome counts and weights used by a recompute
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Appointment reminders, one per appointment and offset before start_time
+CREATE TABLE appointment_reminders (
+    id VARCHAR PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+    tenant_id VARCHAR NOT NULL DEFAULT 'default' REFERENCES tenants(id),
+    appointment_id VARCHAR NOT NULL REFERENCES coach_appointments(id) ON DELETE CASCADE,
+    offset_minutes INTEGER NOT NULL CHECK (offset_minutes > 0),
+    send_at TIMESTAMPTZ NOT NULL,
+    status VARCHAR NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed', 'cancelled')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_attempt TIMESTAMPTZ,
+    last_error TEXT,
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (appointment_id, offset_minutes)
+);
+
+-- Customer feedback, one per appointment, submitted through a signed link
+CREATE TABLE appointment_feedback (
+    id VARCHAR PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+    tenant_id VARCHAR NOT NULL DEFAULT 'default' REFERENCES tenants(id),
+    appointment_id VARCHAR NOT NULL UNIQUE REFERENCES coach_appointments(id) ON DELETE CASCADE,
+    coach_id VARCHAR NOT NULL REFERENCES coaches(id),
+    rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    comment TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
 -- Appointment distribution log (for tracking distribution decisions) - appointment distribution is the process of assigning a certain customer's appointment to a certain coach
 CREATE TABLE distribution_log (
     id VARCHAR PRIMARY KEY DEFAULT uuid_generate_v4()::text,
@@ -118,18 +237,82 @@ CREATE TABLE distribution_log (
     created_at TIMESTAMPTZ DEFAULT NOW()
 );
 
+-- Outbound webhook subscriptions - internal consumers that want booking events pushed to them
+CREATE TABLE webhook_subscriptions (
+    id VARCHAR PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+    tenant_id VARCHAR NOT NULL DEFAULT 'default' REFERENCES tenants(id),
+    url VARCHAR NOT NULL,
+    secret VARCHAR NOT NULL, -- shared HMAC secret used to sign each delivery
+    event_types JSONB NOT NULL, -- array of subscribed event types, e.g. ["appointment.created"]
+    description VARCHAR,
+    active BOOLEAN DEFAULT true,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ
+);
+
+-- Delivery log - one row per event sent to a subscription
+CREATE TABLE webhook_deliveries (
+    id VARCHAR PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+    subscription_id VARCHAR REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    event_id VARCHAR NOT NULL,
+    event_type VARCHAR NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts INTEGER DEFAULT 0,
+    response_status INTEGER,
+    error_message TEXT,
+    last_attempt TIMESTAMPTZ,
+    delivered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Audit log - who changed what; snapshots are JSON as seen by the API
+CREATE TABLE audit_log (
+    id VARCHAR PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+    tenant_id VARCHAR NOT NULL REFERENCES tenants(id),
+    actor_type VARCHAR NOT NULL CHECK (actor_type IN ('api_key', 'webhook', 'system', 'customer')),
+    actor_id VARCHAR, -- api_keys.id, webhook source or job name
+    action VARCHAR NOT NULL, -- e.g. 'appointment.cancelled', 'api_key.rotated'
+    entity_type VARCHAR NOT NULL,
+    entity_id VARCHAR NOT NULL,
+    before_state JSONB,
+    after_state JSONB,
+    request_id VARCHAR,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
 -- Indexes for performance
 CREATE INDEX idx_coach_appointments_start_time ON coach_appointments(start_time);
 CREATE INDEX idx_coach_appointments_coach_id ON coach_appointments(coach_id);
 CREATE INDEX idx_coach_appointments_status ON coach_appointments(status);
 CREATE INDEX idx_coach_appointments_calendar_id ON coach_appointments(calendar_id);
+CREATE INDEX idx_coach_appointments_crm_sync_status ON coach_appointments(crm_sync_status) WHERE crm_sync_status IN ('pending', 'failed');
+CREATE INDEX idx_coach_appointments_calendar_sync_status ON coach_appointments(calendar_sync_status) WHERE calendar_sync_status IN ('pending', 'failed');
+CREATE INDEX idx_coaches_tenant_id ON coaches(tenant_id);
+CREATE INDEX idx_calendars_tenant_id ON calendars(tenant_id);
+CREATE INDEX idx_coach_appointments_tenant_id ON coach_appointments(tenant_id);
+CREATE INDEX idx_webhook_subscriptions_tenant_id ON webhook_subscriptions(tenant_id);
+CREATE INDEX idx_audit_log_tenant_created_at ON audit_log(tenant_id, created_at);
+CREATE INDEX idx_audit_log_entity ON audit_log(entity_type, entity_id);
 CREATE INDEX idx_coach_slots_coach_id ON coach_slots(coach_id);
+CREATE INDEX idx_coach_score_history_coach_created_at ON coach_score_history(coach_id, created_at);
+CREATE INDEX idx_coach_time_off_coach_period ON coach_time_off(coach_id, start_time, end_time);
+CREATE INDEX idx_coach_appointments_needs_reassignment ON coach_appointments(coach_id) WHERE needs_reassignment;
+CREATE INDEX idx_appointment_reminders_due ON appointment_reminders(send_at) WHERE status IN ('pending', 'failed');
+CREATE INDEX idx_appointment_feedback_coach_created_at ON appointment_feedback(coach_id, created_at);
+CREATE INDEX idx_coach_appointments_outcome_due ON coach_appointments(end_time) WHERE status = 'scheduled';
+CREATE INDEX idx_coach_appointments_series ON coach_appointments(series_id, series_index) WHERE series_id IS NOT NULL;
 CREATE INDEX idx_coach_slots_start_time ON coach_slots(start_time);
 CREATE INDEX idx_coach_slots_available ON coach_slots(available);
 CREATE INDEX idx_webhook_events_status ON webhook_events(status);
 CREATE INDEX idx_webhook_events_created_at ON webhook_events(created_at);
+CREATE INDEX idx_webhook_deliveries_subscription_id ON webhook_deliveries(subscription_id, created_at);
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(created_at) WHERE status IN ('pending', 'failed');
 
 -- Insert sample data
+INSERT INTO tenants (id, name) VALUES
+    ('default', 'Default Organization');
+
 INSERT INTO coaches (id, name, email, score) VALUES
     ('coach-1', 'Alice Johnson', 'alice@example.com', 0.85),
     ('coach-2', 'Bob Smith', 'bob@example.com', 0.72),
@@ -143,6 +326,9 @@ INSERT INTO calendars (id, name, calendar_type, slot_duration, slot_interval) VA
     ('cal-2', 'Technical Interview', 'standard', 45, 15),
     ('cal-3', 'Quick Check-in', 'express', 15, 15);
 
+INSERT INTO calendars (id, name, calendar_type, slot_duration, slot_interval, capacity) VALUES
+    ('cal-4', 'Group Workshop', 'group', 60, 30, 8);
+
 -- Map coaches to calendars
 INSERT INTO coach_calendars (coach_id, calendar_id) VALUES
     ('coach-1', 'cal-1'),
@@ -154,13 +340,18 @@ INSERT INTO coach_calendars (coach_id, calendar_id) VALUES
     ('coach-4', 'cal-2'),
     ('coach-5', 'cal-1'),
     ('coach-6', 'cal-1'),
-    ('coach-6', 'cal-2');
+    ('coach-6', 'cal-2'),
+    ('coach-3', 'cal-4'),
+    ('coach-6', 'cal-4');
 
--- Insert sample API keys (in practice, these would be hashed)
-INSERT INTO api_keys (key_hash, name, rate_limit) VALUES
-    ('test-key-123', 'Test API Key', 100),
-    ('dev-key-456', 'Development Key', 500),
-    ('prod-key-789', 'Production Key', 1000);
+-- Insert sample API keys, hashed with the development API_KEY_HASH_SECRET from .env.example
+INSERT INTO api_keys (key_hash, name, key_type, permissions, rate_limit) VALUES
+    (encode(hmac('test-key-123', 'dev-api-key-hash-secret', 'sha256'), 'hex'), 'Test API Key', 'test',
+     '{"read": true, "write": true, "delete": false, "admin": false}', 100),
+    (encode(hmac('dev-key-456', 'dev-api-key-hash-secret', 'sha256'), 'hex'), 'Development Key', 'development',
+     '{"read": true, "write": true, "delete": false, "admin": false}', 500),
+    (encode(hmac('prod-key-789', 'dev-api-key-hash-secret', 'sha256'), 'hex'), 'Production Key', 'production',
+     '{"read": true, "write": true, "delete": true, "admin": true}', 1000);
 
 -- Trigger to update updated_at timestamp
 CREATE OR REPLACE FUNCTION update_updated_at_column()
@@ -181,4 +372,7 @@ CREATE TRIGGER update_coach_appointments_updated_at BEFORE UPDATE ON coach_appoi
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
 CREATE TRIGGER update_coach_slots_updated_at BEFORE UPDATE ON coach_slots
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_webhook_subscriptions_updated_at BEFORE UPDATE ON webhook_subscriptions
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
\ No newline at end of file
@@ -77,6 +77,7 @@ app.post('/validate', (req, res) => {
     // Return validation result
     res.json({
         valid: true,
+        tenant_id: process.env.TENANT_ID || 'default',
         key_type: keyToValidate.includes('test') ? 'test' : 
                   keyToValidate.includes('dev') ? 'development' : 'production',
         rate_limit: {
@@ -87,7 +88,8 @@ app.post('/validate', (req, res) => {
         permissions: {
             read: true,
             write: true,
-            delete: keyToValidate.includes('prod')
+            delete: keyToValidate.includes('prod'),
+            admin: keyToValidate.includes('prod')
         }
     });
 });
@@ -134,6 +136,23 @@ app.post('/keys/:keyId/rotate', (req, res) => {
     });
 });
 
+// Keys managed by the scheduling server, mirrored by hash (never plaintext)
+const managedKeys = new Map();
+
+app.put('/keys/:keyId', (req, res) => {
+    const { keyId } = req.params;
+    const { key_hash, active } = req.body || {};
+
+    if (!key_hash || typeof active !== 'boolean') {
+        return res.status(400).json({
+            error: 'key_hash and active are required'
+        });
+    }
+
+    managedKeys.set(keyId, { ...req.body, synced_at: new Date().toISOString() });
+    res.json({ key_id: keyId, synced: true });
+});
+
 // Clean up rate limit store periodically
 setInterval(() => {
     const now = Date.now();
@@ -9,7 +9,7 @@ import (
 
 func main() {
 	sqlDB := db.Init()
-	rdb := db.InitRDB(sqlDB)
+	rdb := db.InitRDB()
 	s := server.New(sqlDB, rdb)
 	log.Println("Starting server on :3000")
 	if err := s.Start(":3000"); err != nil {
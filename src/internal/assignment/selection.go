@@ -0,0 +1,349 @@
+package assignment
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/db"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// ErrNoCoach means no coach on the calendar can take the appointment.
+var ErrNoCoach = errors.New("no coach available at this time")
+
+// Request describes the appointment a coach is needed for.
+type Request struct {
+	TenantID   string
+	CalendarID string
+	StartTime  time.Time
+	EndTime    time.Time
+	// ExcludeCoachID skips the coach the appointment is moving away from.
+	ExcludeCoachID string
+	// PreferredCoachID wins over higher scored coaches when it passes every
+	// check, so a series keeps its coach.
+	PreferredCoachID string
+	// Capacity is the calendar's seats per session; above 1 the appointment
+	// may join a session a coach already holds at the same time.
+	Capacity int
+}
+
+// Selection is the chosen coach and why.
+type Selection struct {
+	Coach structs.Coach
+	// Considered holds the ids of every coach that passed the calendar, time
+	// off and daily limit checks, for distribution_log.
+	Considered []string
+	Reason     string
+	// Seat is the seat of the session the appointment takes, 1 on calendars
+	// without group sessions.
+	Seat int
+	// Joined is set when the appointment joins a session that already had
+	// attendees, so the coach takes on no new time.
+	Joined bool
+}
+
+// SelectCoach applies the booking rules to the calendar's team: the coach
+// must be active, not on time off, under their daily appointment limit and
+// free for the whole appointment. The preferred coach, if any remains, wins;
+// otherwise the highest scored one does. On group calendars a session with
+// seats left at the same time is filled before a new one is opened.
+// SelectCoach should run in the transaction that writes the appointment so
+// the checks still hold when it commits.
+func SelectCoach(ctx context.Context, q db.Querier, req Request) (*Selection, error) {
+	if req.Capacity > 1 {
+		sel, err := joinSession(ctx, q, req)
+		if err != nil || sel != nil {
+			return sel, err
+		}
+	}
+
+	coaches, err := candidates(ctx, q, req)
+	if err != nil {
+		return nil, err
+	}
+
+	appointmentDay := req.StartTime.Format("2006-01-02")
+
+	var underLimit []structs.Coach
+	for _, coach := range coaches {
+		ok, err := underDailyLimit(ctx, q, coach.ID, appointmentDay)
+		if err != nil {
+			log.Printf("SelectCoach: daily limit check for %s: %v", coach.ID, err)
+			continue
+		}
+		if ok {
+			underLimit = append(underLimit, coach)
+		}
+	}
+
+	var available []structs.Coach
+	considered := []string{}
+	for _, coach := range underLimit {
+		considered = append(considered, coach.ID)
+		ok, err := isFree(ctx, q, coach.ID, req.StartTime, req.EndTime)
+		if err != nil {
+			log.Printf("SelectCoach: availability check for %s: %v", coach.ID, err)
+			continue
+		}
+		if ok {
+			available = append(available, coach)
+		}
+	}
+
+	if len(available) == 0 {
+		return nil, ErrNoCoach
+	}
+
+	for _, c := range available {
+		if c.ID == req.PreferredCoachID {
+			return &Selection{Coach: c, Considered: considered, Reason: "Kept the preferred coach", Seat: 1}, nil
+		}
+	}
+
+	top := available[0]
+	for _, c := range available[1:] {
+		if c.Score > top.Score {
+			top = c
+		}
+	}
+
+	reason := "Selected coach with highest score"
+	if len(available) == 1 {
+		reason = "Only coach available at this time"
+	}
+	if req.PreferredCoachID != "" {
+		reason += " (preferred coach unavailable)"
+	}
+
+	return &Selection{Coach: top, Considered: considered, Reason: reason, Seat: 1}, nil
+}
+
+// joinSession picks among the calendar's sessions that start and end with
+// the request and still have seats: the preferred coach's, else the fullest,
+// then the highest scored. It returns nil when there is none.
+func joinSession(ctx context.Context, q db.Querier, req Request) (*Selection, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT
+			c.id,
+			c.name,
+			c.email,
+			c.score,
+			c.max_daily_appointments,
+			c.working_hours_start,
+			c.working_hours_end,
+			c.timezone,
+			COUNT(*) AS taken
+		FROM coach_appointments a
+		JOIN coaches c ON c.id = a.coach_id
+		JOIN coach_calendars cc ON cc.coach_id = c.id AND cc.calendar_id = a.calendar_id
+		WHERE a.calendar_id = $1
+		  AND a.start_time = $3
+		  AND a.end_time = $4
+		  AND a.status = 'scheduled'
+		  AND NOT a.needs_reassignment
+		  AND c.tenant_id = $2
+		  AND c.active
+		  AND c.id <> $5
+		  AND NOT EXISTS (
+			SELECT 1
+			FROM coach_time_off t
+			WHERE t.coach_id = c.id
+			  AND t.start_time < $4
+			  AND t.end_time > $3
+		  )
+		GROUP BY c.id
+		HAVING COUNT(*) < $6
+		ORDER BY COUNT(*) DESC, c.score DESC
+	`, req.CalendarID, req.TenantID, req.StartTime, req.EndTime, req.ExcludeCoachID, req.Capacity)
+	if err != nil {
+		return nil, fmt.Errorf("query open sessions: %w", err)
+	}
+
+	var sessions []structs.Coach
+	var taken []int
+	considered := []string{}
+	for rows.Next() {
+		var coach structs.Coach
+		var n int
+		err := rows.Scan(
+			&coach.ID,
+			&coach.Name,
+			&coach.Email,
+			&coach.Score,
+			&coach.MaxDailyAppointments,
+			&coach.WorkingHoursStart,
+			&coach.WorkingHoursEnd,
+			&coach.Timezone,
+			&n,
+		)
+		if err != nil {
+			log.Printf("SelectCoach: skipping open session: %v", err)
+			continue
+		}
+		sessions = append(sessions, coach)
+		taken = append(taken, n)
+		considered = append(considered, coach.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+
+	i := 0
+	for j, c := range sessions {
+		if c.ID == req.PreferredCoachID {
+			i = j
+			break
+		}
+	}
+
+	seat, err := freeSeat(ctx, q, sessions[i].ID, req.StartTime, req.Capacity)
+	if err != nil {
+		return nil, err
+	}
+	return &Selection{
+		Coach:      sessions[i],
+		Considered: considered,
+		Reason:     fmt.Sprintf("Joined group session (%d of %d seats taken)", taken[i], req.Capacity),
+		Seat:       seat,
+		Joined:     true,
+	}, nil
+}
+
+// freeSeat returns the lowest seat of the coach's session at start that no
+// scheduled appointment holds, so cancelled seats are reused.
+func freeSeat(ctx context.Context, q db.Querier, coachID string, start time.Time, capacity int) (int, error) {
+	var seat int
+	err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(MIN(g.seat), 0)
+		FROM generate_series(1, $3::int) AS g(seat)
+		WHERE NOT EXISTS (
+			SELECT 1
+			FROM coach_appointments a
+			WHERE a.coach_id = $1
+			  AND a.start_time = $2
+			  AND a.seat = g.seat
+			  AND a.status = 'scheduled'
+		)
+	`, coachID, start, capacity).Scan(&seat)
+	if err != nil {
+		return 0, fmt.Errorf("find free seat: %w", err)
+	}
+	if seat == 0 {
+		return 0, ErrNoCoach
+	}
+	return seat, nil
+}
+
+func candidates(ctx context.Context, q db.Querier, req Request) ([]structs.Coach, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT
+			c.id,
+			c.name,
+			c.email,
+			c.score,
+			c.max_daily_appointments,
+			c.working_hours_start,
+			c.working_hours_end,
+			c.timezone
+		FROM coaches c
+		WHERE c.id IN (
+			SELECT coach_id
+			FROM coach_calendars cc
+			WHERE cc.calendar_id = $1
+		)
+		AND c.tenant_id = $2
+		AND c.active
+		AND c.id <> $5
+		AND NOT EXISTS (
+			SELECT 1
+			FROM coach_time_off t
+			WHERE t.coach_id = c.id
+			  AND t.start_time < $4
+			  AND t.end_time > $3
+		)
+	`, req.CalendarID, req.TenantID, req.StartTime, req.EndTime, req.ExcludeCoachID)
+	if err != nil {
+		return nil, fmt.Errorf("query candidate coaches: %w", err)
+	}
+	defer rows.Close()
+
+	var coaches []structs.Coach
+	for rows.Next() {
+		var coach structs.Coach
+		err := rows.Scan(
+			&coach.ID,
+			&coach.Name,
+			&coach.Email,
+			&coach.Score,
+			&coach.MaxDailyAppointments,
+			&coach.WorkingHoursStart,
+			&coach.WorkingHoursEnd,
+			&coach.Timezone,
+		)
+		if err != nil {
+			log.Printf("SelectCoach: skipping candidate coach: %v", err)
+			continue
+		}
+		coaches = append(coaches, coach)
+	}
+	return coaches, rows.Err()
+}
+
+// underDailyLimit counts the coach's scheduled sessions within their working
+// hours on day; attendees of one group session count once.
+func underDailyLimit(ctx context.Context, q db.Querier, coachID, day string) (bool, error) {
+	var current, maxDaily int
+	err := q.QueryRowContext(ctx, `SELECT COUNT(DISTINCT ca.start_time), c.max_daily_appointments
+	FROM coach_appointments ca
+	JOIN coaches c ON ca.coach_id = c.id
+	WHERE ca.coach_id = $1
+	  AND ca.start_time >= ($2::date + c.working_hours_start::interval)
+	  AND ca.end_time <= ($2::date + c.working_hours_end::interval)
+	  AND ca.status = 'scheduled'
+	GROUP BY c.max_daily_appointments;
+	`, coachID, day).Scan(&current, &maxDaily)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return current < maxDaily, nil
+}
+
+// isFree reports whether every 15-minute slot of [start, end) is available
+// for the coach and no scheduled appointment overlaps it.
+func isFree(ctx context.Context, q db.Querier, coachID string, start, end time.Time) (bool, error) {
+	var free bool
+	err := q.QueryRowContext(ctx, `
+    SELECT
+        NOT EXISTS (
+            SELECT 1
+            FROM generate_series($2::timestamptz, $3::timestamptz - interval '15 minutes', interval '15 minutes') AS g(t)
+            WHERE NOT EXISTS (
+                SELECT 1
+                FROM coach_slots s
+                WHERE s.coach_id = $1
+                  AND s.start_time = g.t
+                  AND s.available = TRUE
+            )
+        )
+        AND NOT EXISTS (
+            SELECT 1
+            FROM coach_appointments a
+            WHERE a.coach_id = $1
+              AND a.start_time < $3
+              AND a.end_time > $2
+              AND a.status = 'scheduled'
+        ) AS is_available
+`, coachID, start, end).Scan(&free)
+	return free, err
+}
@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/transistxr/coach-assignment-server/src/internal/auth"
+)
+
+// Actor types recorded in audit_log.actor_type.
+const (
+	ActorAPIKey  = "api_key"
+	ActorWebhook = "webhook"
+	ActorSystem  = "system"
+
+	// ActorCustomer acts through a signed link; its ID is the appointment.
+	ActorCustomer = "customer"
+)
+
+// Actions that are not also outbound event types.
+const (
+	ActionSubscriptionCreated     = "subscription.created"
+	ActionSubscriptionDeactivated = "subscription.deactivated"
+	ActionKeyCreated              = "api_key.created"
+	ActionKeyDeactivated          = "api_key.deactivated"
+	ActionKeyRotated              = "api_key.rotated"
+	ActionCoachCreated            = "coach.created"
+	ActionCoachUpdated            = "coach.updated"
+	ActionCoachDeactivated        = "coach.deactivated"
+	ActionCoachImported           = "coach.imported"
+	ActionCoachScoreChanged       = "coach.score_changed"
+	ActionCoachTimeOffAdded       = "coach.time_off_added"
+	ActionCoachTimeOffRemoved     = "coach.time_off_removed"
+	ActionCalendarCreated         = "calendar.created"
+	ActionCalendarUpdated         = "calendar.updated"
+	ActionCalendarDeactivated     = "calendar.deactivated"
+	ActionCalendarCoachAdded      = "calendar.coach_added"
+	ActionCalendarCoachRemoved    = "calendar.coach_removed"
+	ActionFeedbackSubmitted       = "appointment.feedback_submitted"
+)
+
+// Actor is who made a change.
+type Actor struct {
+	Type string
+	ID   string
+}
+
+type actorKey struct{}
+
+// WithActor overrides the actor for changes made with ctx, for callers that
+// are not API keys such as signed webhooks and background jobs.
+func WithActor(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, a)
+}
+
+// actorFrom prefers an explicit actor, then the authenticated principal, and
+// falls back to the system.
+func actorFrom(ctx context.Context) Actor {
+	if a, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return a
+	}
+	if p, ok := auth.PrincipalFrom(ctx); ok {
+		return Actor{Type: ActorAPIKey, ID: p.KeyID}
+	}
+	return Actor{Type: ActorSystem}
+}
+
+// Entry describes one change. Before and After are stored as JSON snapshots;
+// either may be nil for creations and deletions. TenantID defaults to the
+// principal's tenant.
+type Entry struct {
+	TenantID   string
+	Action     string
+	EntityType string
+	EntityID   string
+	Before     any
+	After      any
+}
+
+// Logger writes audit_log rows. Recording is best effort: a failed write is
+// logged and never undoes the change it describes.
+type Logger struct {
+	DB *sql.DB
+}
+
+func NewLogger(sqlDB *sql.DB) *Logger {
+	return &Logger{DB: sqlDB}
+}
+
+func (l *Logger) Record(ctx context.Context, e Entry) {
+	actor := actorFrom(ctx)
+
+	tenantID := e.TenantID
+	if tenantID == "" {
+		tenantID = auth.TenantFrom(ctx)
+	}
+
+	_, err := l.DB.ExecContext(ctx, `
+INSERT INTO audit_log (tenant_id, actor_type, actor_id, action, entity_type, entity_id,
+  before_state, after_state, request_id)
+VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, NULLIF($9, ''))`,
+		tenantID, actor.Type, actor.ID, e.Action, e.EntityType, e.EntityID,
+		snapshot(e.Before), snapshot(e.After), middleware.GetReqID(ctx))
+	if err != nil {
+		log.Printf("Audit: failed to record %s on %s %s: %v", e.Action, e.EntityType, e.EntityID, err)
+	}
+}
+
+func snapshot(v any) []byte {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Audit: failed to marshal snapshot: %v", err)
+		return nil
+	}
+	return b
+}
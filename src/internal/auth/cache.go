@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/transistxr/coach-assignment-server/src/internal/db"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+const maxLocalEntries = 10000
+
+type cacheEntry struct {
+	resp    *structs.ValidateResponse
+	expires time.Time
+}
+
+// Cache holds ValidateResponses by key hash, in process and in Redis. The
+// in-process layer has a shorter TTL because other replicas cannot
+// invalidate it. Invalid keys are cached for NegativeTTL so a flood of bad
+// keys doesn't reach the database or the auth service either.
+type Cache struct {
+	rdb         *db.RedisClient
+	TTL         time.Duration
+	LocalTTL    time.Duration
+	NegativeTTL time.Duration
+
+	mu    sync.Mutex
+	local map[string]cacheEntry
+}
+
+// NewCacheFromEnv reads AUTH_CACHE_TTL_SECONDS, AUTH_CACHE_LOCAL_TTL_SECONDS
+// and AUTH_CACHE_NEGATIVE_TTL_SECONDS.
+func NewCacheFromEnv(rdb *db.RedisClient) *Cache {
+	return &Cache{
+		rdb:         rdb,
+		TTL:         envSeconds("AUTH_CACHE_TTL_SECONDS", 60*time.Second),
+		LocalTTL:    envSeconds("AUTH_CACHE_LOCAL_TTL_SECONDS", 5*time.Second),
+		NegativeTTL: envSeconds("AUTH_CACHE_NEGATIVE_TTL_SECONDS", 10*time.Second),
+		local:       make(map[string]cacheEntry),
+	}
+}
+
+func envSeconds(name string, def time.Duration) time.Duration {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v > 0 {
+		return time.Duration(v) * time.Second
+	}
+	return def
+}
+
+func redisKey(keyHash string) string {
+	return "auth:" + keyHash
+}
+
+// redisKeyIDKey indexes cached validations by key id, for invalidation by id.
+func redisKeyIDKey(keyID string) string {
+	return "auth-key-id:" + keyID
+}
+
+func (c *Cache) Get(ctx context.Context, keyHash string) (*structs.ValidateResponse, bool) {
+	c.mu.Lock()
+	entry, ok := c.local[keyHash]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.resp, true
+	}
+
+	val, err := c.rdb.Client.Get(ctx, redisKey(keyHash)).Bytes()
+	if err != nil {
+		return nil, false
+	}
+
+	var resp structs.ValidateResponse
+	if err := json.Unmarshal(val, &resp); err != nil {
+		return nil, false
+	}
+	c.setLocal(keyHash, &resp, c.ttlFor(&resp))
+	return &resp, true
+}
+
+func (c *Cache) Set(ctx context.Context, keyHash string, resp *structs.ValidateResponse) {
+	ttl := c.ttlFor(resp)
+	c.setLocal(keyHash, resp, ttl)
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	if err := c.rdb.Client.Set(ctx, redisKey(keyHash), b, ttl).Err(); err != nil {
+		log.Printf("Auth cache: failed to store validation result: %v", err)
+	}
+	if resp.KeyID != "" {
+		if err := c.rdb.Client.Set(ctx, redisKeyIDKey(resp.KeyID), keyHash, ttl).Err(); err != nil {
+			log.Printf("Auth cache: failed to index validation result: %v", err)
+		}
+	}
+}
+
+// Delete drops keyHash from both layers.
+func (c *Cache) Delete(ctx context.Context, keyHash string) {
+	c.mu.Lock()
+	delete(c.local, keyHash)
+	c.mu.Unlock()
+
+	if err := c.rdb.Client.Del(ctx, redisKey(keyHash)).Err(); err != nil {
+		log.Printf("Auth cache: failed to invalidate key: %v", err)
+	}
+}
+
+// DeleteKeyID drops the cached validation of the key with id keyID from both
+// layers.
+func (c *Cache) DeleteKeyID(ctx context.Context, keyID string) {
+	c.mu.Lock()
+	for keyHash, e := range c.local {
+		if e.resp.KeyID == keyID {
+			delete(c.local, keyHash)
+		}
+	}
+	c.mu.Unlock()
+
+	keyHash, err := c.rdb.Client.Get(ctx, redisKeyIDKey(keyID)).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Auth cache: failed to look up key %s: %v", keyID, err)
+		}
+		return
+	}
+	c.Delete(ctx, keyHash)
+	if err := c.rdb.Client.Del(ctx, redisKeyIDKey(keyID)).Err(); err != nil {
+		log.Printf("Auth cache: failed to invalidate key %s: %v", keyID, err)
+	}
+}
+
+// ttlFor never lets a rotated key outlive its grace period in the cache.
+func (c *Cache) ttlFor(resp *structs.ValidateResponse) time.Duration {
+	if !resp.Valid {
+		return c.NegativeTTL
+	}
+	if resp.ExpiresAt != nil {
+		return max(min(c.TTL, time.Until(*resp.ExpiresAt)), time.Second)
+	}
+	return c.TTL
+}
+
+func (c *Cache) setLocal(keyHash string, resp *structs.ValidateResponse, ttl time.Duration) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.local) >= maxLocalEntries {
+		for k, e := range c.local {
+			if now.After(e.expires) {
+				delete(c.local, k)
+			}
+		}
+		if len(c.local) >= maxLocalEntries {
+			c.local = make(map[string]cacheEntry)
+		}
+	}
+	c.local[keyHash] = cacheEntry{resp: resp, expires: now.Add(min(ttl, c.LocalTTL))}
+}
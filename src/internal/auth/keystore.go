@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/clients"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// KeyStore validates API keys locally against api_keys.key_hash, which holds
+// HMAC-SHA256(API_KEY_HASH_SECRET, key). Keys unknown to the table are only
+// checked with the remote auth service when fallback is enabled.
+type KeyStore struct {
+	DB     *sql.DB
+	Cache  *Cache
+	secret []byte
+	remote *clients.AuthClient
+}
+
+// NewKeyStoreFromEnv reads API_KEY_HASH_SECRET and AUTH_REMOTE_FALLBACK; the
+// remote client is only used when the latter is true. Without the secret no
+// stored key could ever match, so startup is aborted.
+func NewKeyStoreFromEnv(sqlDB *sql.DB, cache *Cache, remote *clients.AuthClient) *KeyStore {
+	secret := os.Getenv("API_KEY_HASH_SECRET")
+	if secret == "" {
+		log.Fatal("API_KEY_HASH_SECRET is not set, API keys cannot be validated")
+	}
+
+	s := &KeyStore{
+		DB:     sqlDB,
+		Cache:  cache,
+		secret: []byte(secret),
+	}
+	if fallback, _ := strconv.ParseBool(os.Getenv("AUTH_REMOTE_FALLBACK")); fallback {
+		s.remote = remote
+	}
+	return s
+}
+
+// HashKey returns the value stored in api_keys.key_hash for apiKey.
+func (s *KeyStore) HashKey(apiKey string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(apiKey))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateKey answers in the same shape as the remote auth service, from the
+// cache when possible. An error is only returned when the key could not be
+// checked at all.
+func (s *KeyStore) ValidateKey(ctx context.Context, apiKey string) (*structs.ValidateResponse, error) {
+	keyHash := s.HashKey(apiKey)
+	if resp, ok := s.Cache.Get(ctx, keyHash); ok {
+		return resp, nil
+	}
+
+	resp, err := s.validate(ctx, apiKey, keyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// A rate-limited answer from the auth service says nothing about the key.
+	if resp.Valid || resp.RetryAfter == 0 {
+		s.Cache.Set(ctx, keyHash, resp)
+	}
+	return resp, nil
+}
+
+// Invalidate drops any cached validation of apiKey, e.g. after it was rotated.
+func (s *KeyStore) Invalidate(ctx context.Context, apiKey string) {
+	s.Cache.Delete(ctx, s.HashKey(apiKey))
+}
+
+// InvalidateHash is Invalidate for callers that only have api_keys.key_hash.
+func (s *KeyStore) InvalidateHash(ctx context.Context, keyHash string) {
+	s.Cache.Delete(ctx, keyHash)
+}
+
+// InvalidateKeyID drops the cached validations of the key with id keyID,
+// which may also be a key only the remote auth service knows.
+func (s *KeyStore) InvalidateKeyID(ctx context.Context, keyID string) {
+	s.Cache.DeleteKeyID(ctx, keyID)
+}
+
+func (s *KeyStore) validate(ctx context.Context, apiKey string, keyHash string) (*structs.ValidateResponse, error) {
+	var keyID, tenantID, keyType string
+	var active bool
+	var rateLimit int
+	var permissions []byte
+	var expiresAt sql.NullTime
+	err := s.DB.QueryRowContext(ctx, `
+SELECT id, tenant_id, key_type, active, rate_limit, permissions, expires_at
+FROM api_keys WHERE key_hash = $1`, keyHash).Scan(&keyID, &tenantID, &keyType, &active, &rateLimit, &permissions, &expiresAt)
+	if err == sql.ErrNoRows {
+		if s.remote != nil {
+			log.Println("API key not found locally, falling back to auth service")
+			return s.remote.ValidateKey(ctx, apiKey)
+		}
+		return &structs.ValidateResponse{Valid: false, Error: "Invalid API key"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !active {
+		return &structs.ValidateResponse{Valid: false, Error: "API key is inactive"}, nil
+	}
+	if expiresAt.Valid && !expiresAt.Time.After(time.Now()) {
+		return &structs.ValidateResponse{Valid: false, Error: "API key has expired"}, nil
+	}
+
+	var perms structs.Permissions
+	if err := json.Unmarshal(permissions, &perms); err != nil {
+		log.Printf("KeyStore: invalid permissions for key %s: %v", keyID, err)
+	}
+
+	// last_used_at only needs minute precision; skipping fresher rows keeps
+	// hot keys from turning every request into a write.
+	_, err = s.DB.ExecContext(ctx, `
+UPDATE api_keys SET last_used_at = NOW()
+WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - interval '1 minute')`, keyID)
+	if err != nil {
+		log.Printf("KeyStore: failed to update last_used_at for key %s: %v", keyID, err)
+	}
+
+	resp := &structs.ValidateResponse{
+		Valid:       true,
+		KeyID:       keyID,
+		TenantID:    tenantID,
+		KeyType:     keyType,
+		RateLimit:   &structs.RateLimit{Limit: rateLimit},
+		Permissions: &perms,
+	}
+	if expiresAt.Valid {
+		resp.ExpiresAt = &expiresAt.Time
+	}
+	return resp, nil
+}
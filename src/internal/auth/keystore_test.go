@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/breaker"
+	"github.com/transistxr/coach-assignment-server/src/internal/clients"
+	"github.com/transistxr/coach-assignment-server/src/internal/db/redistest"
+	"github.com/transistxr/coach-assignment-server/src/internal/retry"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+func TestRotateKeyInvalidatesCachedValidation(t *testing.T) {
+	ctx := context.Background()
+	rdb, redis := redistest.New(t)
+
+	store := &KeyStore{
+		Cache: &Cache{
+			rdb:         rdb,
+			TTL:         time.Minute,
+			LocalTTL:    time.Minute,
+			NegativeTTL: time.Minute,
+			local:       map[string]cacheEntry{},
+		},
+		secret: []byte("test-secret"),
+	}
+
+	authService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/keys/key-1/rotate" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"old_key": "old-key", "new_key": "new-key"}`))
+	}))
+	defer authService.Close()
+
+	client := clients.NewAuthClient(authService.URL, retry.Policy{MaxAttempts: 1}, breaker.New("auth", 5, time.Minute))
+	client.Invalidator = store
+
+	oldHash := store.HashKey("old-key")
+	otherHash := store.HashKey("other-key")
+	store.Cache.Set(ctx, oldHash, &structs.ValidateResponse{Valid: true, KeyID: "key-1"})
+	store.Cache.Set(ctx, otherHash, &structs.ValidateResponse{Valid: true, KeyID: "key-2"})
+
+	if _, err := client.RotateKey(ctx, "key-1"); err != nil {
+		t.Fatalf("RotateKey: %v", err)
+	}
+
+	if _, ok := store.Cache.Get(ctx, oldHash); ok {
+		t.Error("rotated key is still cached")
+	}
+	if redis.Has(redisKey(oldHash)) || redis.Has(redisKeyIDKey("key-1")) {
+		t.Error("rotated key is still cached in redis")
+	}
+	if _, ok := store.Cache.Get(ctx, otherHash); !ok {
+		t.Error("another key's validation was dropped")
+	}
+}
+
+func TestInvalidateKeyIDAfterLocalExpiry(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := redistest.New(t)
+
+	cache := &Cache{rdb: rdb, TTL: time.Minute, LocalTTL: time.Minute, NegativeTTL: time.Minute, local: map[string]cacheEntry{}}
+	cache.Set(ctx, "hash-1", &structs.ValidateResponse{Valid: true, KeyID: "key-1"})
+
+	// Another replica cached the key: only redis holds it here.
+	cache.local = map[string]cacheEntry{}
+
+	cache.DeleteKeyID(ctx, "key-1")
+	if _, ok := cache.Get(ctx, "hash-1"); ok {
+		t.Error("validation cached by another replica survived invalidation")
+	}
+}
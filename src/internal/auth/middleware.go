@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/transistxr/coach-assignment-server/src/internal/breaker"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// Permission is a scope granted to an API key through api_keys.permissions.
+type Permission string
+
+const (
+	PermissionRead   Permission = "read"
+	PermissionWrite  Permission = "write"
+	PermissionDelete Permission = "delete"
+	PermissionAdmin  Permission = "admin"
+)
+
+// Has reports whether perms grants p. Keys without a permissions object are
+// granted nothing.
+func Has(perms *structs.Permissions, p Permission) bool {
+	if perms == nil {
+		return false
+	}
+	switch p {
+	case PermissionRead:
+		return perms.Read
+	case PermissionWrite:
+		return perms.Write
+	case PermissionDelete:
+		return perms.Delete
+	case PermissionAdmin:
+		return perms.Admin
+	}
+	return false
+}
+
+// Authenticate validates X-API-Key and attaches the key's Principal to the
+// request context. Requests without a valid key never reach next.
+func (s *KeyStore) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "X-API-Key Header is missing", "")
+			return
+		}
+
+		resp, err := s.ValidateKey(r.Context(), apiKey)
+		if errors.Is(err, breaker.ErrOpen) {
+			writeError(w, http.StatusServiceUnavailable, "DOWNSTREAM_UNAVAILABLE", "Authentication service is unavailable", "")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "DOWNSTREAM_ERROR", "Downstream service failure", err.Error())
+			return
+		}
+		if !resp.Valid {
+			writeError(w, http.StatusUnauthorized, "VALIDATION_ERROR", "Invalid API Key", "")
+			return
+		}
+		// Every request is scoped to its key's tenant; a key without one,
+		// e.g. from an auth service that doesn't know tenants, can't be used.
+		if resp.TenantID == "" {
+			writeError(w, http.StatusForbidden, "PERMISSION_DENIED", "API key is not assigned to a tenant", "key "+resp.KeyID+" has no tenant")
+			return
+		}
+
+		p := &Principal{
+			KeyID:       resp.KeyID,
+			TenantID:    resp.TenantID,
+			KeyHash:     s.HashKey(apiKey),
+			KeyType:     resp.KeyType,
+			Permissions: resp.Permissions,
+		}
+		if resp.RateLimit != nil {
+			p.RateLimit = resp.RateLimit.Limit
+		}
+		log.Printf("[%s] %s %s authenticated as key %s (%s) of tenant %s", middleware.GetReqID(r.Context()), r.Method, r.URL.Path, p.KeyID, p.KeyType, p.TenantID)
+
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	})
+}
+
+// RequirePermission returns middleware that rejects requests whose principal
+// lacks p with 403, naming the missing permission. It must run after
+// Authenticate.
+func RequirePermission(p Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFrom(r.Context())
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "VALIDATION_ERROR", "Request is not authenticated", "")
+				return
+			}
+			if !principal.Can(p) {
+				writeError(w, http.StatusForbidden, "PERMISSION_DENIED", "API key lacks the '"+string(p)+"' permission", "missing permission: "+string(p))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code, message, details string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(structs.BaseResponse{Error: code, Message: message, ErrorDetails: details})
+}
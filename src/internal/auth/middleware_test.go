@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/db/redistest"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+func TestAuthenticateRejectsKeysWithoutTenant(t *testing.T) {
+	ctx := context.Background()
+	rdb, _ := redistest.New(t)
+
+	store := &KeyStore{
+		Cache: &Cache{
+			rdb:         rdb,
+			TTL:         time.Minute,
+			LocalTTL:    time.Minute,
+			NegativeTTL: time.Minute,
+			local:       map[string]cacheEntry{},
+		},
+		secret: []byte("test-secret"),
+	}
+	store.Cache.Set(ctx, store.HashKey("tenant-key"), &structs.ValidateResponse{Valid: true, KeyID: "key-1", TenantID: "acme"})
+	store.Cache.Set(ctx, store.HashKey("remote-key"), &structs.ValidateResponse{Valid: true, KeyID: "key-2"})
+
+	var tenant string
+	handler := store.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant = TenantFrom(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tc := range []struct {
+		key        string
+		wantStatus int
+		wantTenant string
+	}{
+		{"tenant-key", http.StatusOK, "acme"},
+		{"remote-key", http.StatusForbidden, ""},
+	} {
+		t.Run(tc.key, func(t *testing.T) {
+			tenant = ""
+			req := httptest.NewRequest(http.MethodGet, "/api/coaches", nil)
+			req.Header.Set("X-API-Key", tc.key)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if tenant != tc.wantTenant {
+				t.Errorf("tenant = %q, want %q", tenant, tc.wantTenant)
+			}
+		})
+	}
+}
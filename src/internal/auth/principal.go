@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// Principal is the API key a request was authenticated with. All data a
+// request can see or change is limited to the principal's tenant.
+type Principal struct {
+	KeyID       string
+	TenantID    string
+	KeyHash     string
+	KeyType     string
+	RateLimit   int
+	Permissions *structs.Permissions
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal attached by Authenticate, if any.
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// TenantFrom returns the tenant of the authenticated principal, or "" for a
+// request without one, which matches no tenant's rows.
+func TenantFrom(ctx context.Context) string {
+	if p, ok := PrincipalFrom(ctx); ok {
+		return p.TenantID
+	}
+	return ""
+}
+
+// Can reports whether the principal was granted perm.
+func (p *Principal) Can(perm Permission) bool {
+	return Has(p.Permissions, perm)
+}
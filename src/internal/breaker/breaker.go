@@ -0,0 +1,141 @@
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	Closed   State = "closed"
+	Open     State = "open"
+	HalfOpen State = "half-open"
+)
+
+// ErrOpen is returned instead of calling a downstream whose breaker is open.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// Breaker stops calls to a downstream after Threshold consecutive failures.
+// Once Cooldown has passed it lets a single probe through (half-open); the
+// probe's outcome either closes the breaker or re-opens it for another
+// Cooldown.
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func New(name string, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		name:      name,
+		threshold: max(threshold, 1),
+		cooldown:  cooldown,
+		state:     Closed,
+	}
+}
+
+// FromEnv builds a breaker using CIRCUIT_BREAKER_FAILURE_THRESHOLD and
+// CIRCUIT_BREAKER_COOLDOWN_SECONDS.
+func FromEnv(name string) *Breaker {
+	threshold := 5
+	if v, err := strconv.Atoi(os.Getenv("CIRCUIT_BREAKER_FAILURE_THRESHOLD")); err == nil && v > 0 {
+		threshold = v
+	}
+
+	cooldown := 30 * time.Second
+	if v, err := strconv.Atoi(os.Getenv("CIRCUIT_BREAKER_COOLDOWN_SECONDS")); err == nil && v > 0 {
+		cooldown = time.Duration(v) * time.Second
+	}
+
+	return New(name, threshold, cooldown)
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// Allow reports whether a call may proceed. In the half-open state only one
+// probe is allowed at a time; every caller that gets nil must report the
+// outcome with Success or Failure.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if time.Since(b.openedAt) < b.cooldown {
+			return fmt.Errorf("%s: %w", b.name, ErrOpen)
+		}
+		b.setState(HalfOpen)
+		b.probing = true
+		return nil
+	case HalfOpen:
+		if b.probing {
+			return fmt.Errorf("%s: %w", b.name, ErrOpen)
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	b.setState(Closed)
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == HalfOpen || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		b.setState(Open)
+	}
+}
+
+// Ignore releases a probe slot without recording an outcome, for calls the
+// caller abandoned before the downstream answered.
+func (b *Breaker) Ignore() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// State returns the current state, reporting an open breaker whose cooldown
+// has elapsed as half-open.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open && time.Since(b.openedAt) >= b.cooldown {
+		return HalfOpen
+	}
+	return b.state
+}
+
+func (b *Breaker) setState(s State) {
+	if b.state != s {
+		log.Printf("Circuit breaker %s: %s -> %s", b.name, b.state, s)
+		b.state = s
+	}
+}
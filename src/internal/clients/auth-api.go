@@ -1,14 +1,16 @@
 package clients
 
 import (
-	//"bytes"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
-	"log"
 
+	"github.com/transistxr/coach-assignment-server/src/internal/breaker"
+	"github.com/transistxr/coach-assignment-server/src/internal/retry"
 	"github.com/transistxr/coach-assignment-server/src/internal/structs"
 )
 
@@ -18,35 +20,66 @@ import (
 type AuthClient struct {
 	baseURL    string
 	httpClient *http.Client
+	retry      retry.Policy
+	Breaker    *breaker.Breaker
+
+	// Invalidator, when set, is told about keys replaced by RotateKey so
+	// cached validations of the old key are dropped.
+	Invalidator KeyInvalidator
 }
 
-func NewAuthClient(baseURL string) *AuthClient {
+// KeyInvalidator drops cached validations of a key given its id, the only
+// thing RotateKey knows about the key it replaced.
+type KeyInvalidator interface {
+	InvalidateKeyID(ctx context.Context, keyID string)
+}
+
+// NewAuthClient only retries transport errors and 5xx responses: a 429 from
+// the auth service is the caller's rate limit, not a transient failure.
+func NewAuthClient(baseURL string, policy retry.Policy, b *breaker.Breaker) *AuthClient {
 	return &AuthClient{
 		baseURL:    baseURL,
 		httpClient: &http.Client{Timeout: 10 * time.Second},
+		retry:      policy.WithRetryable(retry.ServerErrorsOnly),
+		Breaker:    b,
 	}
 }
 
+func (c *AuthClient) do(ctx context.Context, method, url string, header http.Header, body []byte) (*http.Response, error) {
+	return guarded(ctx, c.Breaker, func() (*http.Response, error) {
+		return c.retry.Do(ctx, func(ctx context.Context) (*http.Response, error) {
+			req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range header {
+				req.Header[k] = v
+			}
+			return c.httpClient.Do(req)
+		})
+	})
+}
 
 func (c *AuthClient) ValidateKey(ctx context.Context, apiKey string) (*structs.ValidateResponse, error) {
 	url := fmt.Sprintf("%s/validate", c.baseURL)
 
-	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
-	req.Header.Set("Content-Type", "application/json")
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
 	if apiKey != "" {
-		req.Header.Set("X-API-Key", apiKey)
+		header.Set("X-API-Key", apiKey)
 	}
 
-	log.Println("Request to Auth Service: ", req)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(ctx, http.MethodPost, url, header, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	log.Println("Response from Auth Service: ", resp, resp.Body)
-
+	// Invalid and rate-limited keys are reported with 4xx statuses and a
+	// ValidateResponse body, so only server errors are failures here.
+	if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
 
 	var out structs.ValidateResponse
 	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
@@ -59,18 +92,18 @@ func (c *AuthClient) ValidateKey(ctx context.Context, apiKey string) (*structs.V
 
 func (c *AuthClient) GetKeyInfo(ctx context.Context, keyID string) (*structs.KeyInfo, error) {
 	url := fmt.Sprintf("%s/keys/%s/info", c.baseURL, keyID)
-	resp, err := c.httpClient.Get(url)
+	resp, err := c.do(ctx, http.MethodGet, url, nil, nil)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
 		return nil, fmt.Errorf("key %s not found", keyID)
 	}
 
 	var out structs.KeyInfo
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+	if err := decodeResponse(resp, &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
@@ -79,20 +112,45 @@ func (c *AuthClient) GetKeyInfo(ctx context.Context, keyID string) (*structs.Key
 func (c *AuthClient) RotateKey(ctx context.Context, keyID string) (*structs.RotateKeyResponse, error) {
 	url := fmt.Sprintf("%s/keys/%s/rotate", c.baseURL, keyID)
 
-	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(ctx, http.MethodPost, url, nil, nil)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
 		return nil, fmt.Errorf("key %s not found", keyID)
 	}
 
 	var out structs.RotateKeyResponse
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+	if err := decodeResponse(resp, &out); err != nil {
 		return nil, err
 	}
+
+	if c.Invalidator != nil {
+		c.Invalidator.InvalidateKeyID(ctx, keyID)
+	}
 	return &out, nil
 }
+
+// SyncKey mirrors a locally managed API key to the auth service so both agree
+// on which keys exist and whether they are active.
+func (c *AuthClient) SyncKey(ctx context.Context, key *structs.SyncKeyRequest) error {
+	url := fmt.Sprintf("%s/keys/%s", c.baseURL, key.ID)
+
+	b, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(ctx, http.MethodPut, url, header, b)
+	if err != nil {
+		return err
+	}
+
+	var out map[string]any
+	return decodeResponse(resp, &out)
+}
@@ -1,195 +1,125 @@
 package clients
 
 import (
-	"fmt"
+	"bytes"
+	"context"
 	"encoding/json"
-	"strconv"
-	"math/rand"
-	"os"
+	"fmt"
 	"log"
-
 	"net/http"
 	"time"
-	"bytes"
 
+	"github.com/transistxr/coach-assignment-server/src/internal/breaker"
+	"github.com/transistxr/coach-assignment-server/src/internal/retry"
 	"github.com/transistxr/coach-assignment-server/src/internal/structs"
 )
 
-
 // AvailabilityClient is a client wrapper for the external Calendar API.
 // It fetches raw availability data for a coach and lets you block and release slots
 type AvailabilityClient struct {
 	BaseURL string
 	Client  *http.Client
+	Retry   retry.Policy
+	Breaker *breaker.Breaker
 }
 
-func NewAvailabilityClient(baseURL string) *AvailabilityClient {
+func NewAvailabilityClient(baseURL string, policy retry.Policy, b *breaker.Breaker) *AvailabilityClient {
 	return &AvailabilityClient{
 		BaseURL: baseURL,
 		Client:  &http.Client{Timeout: 10 * time.Second},
+		Retry:   policy,
+		Breaker: b,
 	}
 }
 
-func (c *AvailabilityClient) GetAvailability(coachID string, days int) (*structs.GetAvailabilityResponse, error) {
-
-	log.Printf("Sending request to Calendar API for coach %s", coachID)
-
-	url := fmt.Sprintf("%s/coaches/%s/availability?days=%d", c.BaseURL, coachID, days)
+func (c *AvailabilityClient) get(ctx context.Context, url string, out any) error {
+	resp, err := guarded(ctx, c.Breaker, func() (*http.Response, error) {
+		return c.Retry.Do(ctx, func(ctx context.Context) (*http.Response, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return nil, err
+			}
+			return c.Client.Do(req)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("error calling API: %w", err)
+	}
+	return decodeResponse(resp, out)
+}
 
-    maxAttempts, err := strconv.Atoi(os.Getenv("WEBHOOK_RETRY_ATTEMPTS"))
+func (c *AvailabilityClient) post(ctx context.Context, url string, body any, out any) error {
+	b, err := json.Marshal(body)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-    webhookDelay, err := strconv.Atoi(os.Getenv("WEBHOOK_RETRY_DELAY_MS"))
+	resp, err := guarded(ctx, c.Breaker, func() (*http.Response, error) {
+		return c.Retry.Do(ctx, func(ctx context.Context) (*http.Response, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			return c.Client.Do(req)
+		})
+	})
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("error calling API: %w", err)
 	}
+	return decodeResponse(resp, out)
+}
 
+func (c *AvailabilityClient) GetAvailability(ctx context.Context, coachID string, days int) (*structs.GetAvailabilityResponse, error) {
 
-	baseDelay := time.Duration(webhookDelay) * time.Millisecond
+	log.Printf("Sending request to Calendar API for coach %s", coachID)
 
-	var lastErr error
-	var out structs.GetAvailabilityResponse
-	for attempt := range maxAttempts {
-		log.Printf("Attempt No. %d", attempt)
-        resp, err := c.Client.Get(url)
-        if err != nil {
-            lastErr = err
-        } else {
-            defer resp.Body.Close()
-
-            if resp.StatusCode >= 500 {
-				log.Printf("Failed, retrying..")
-            } else if resp.StatusCode >= 400 {
-                return nil, err
-            } else {
-                if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-                    return nil, err
-                }
-				log.Printf("Recieved success!")
-
-                return &out, nil
-            }
-        }
-
-        sleep := baseDelay * (1 << attempt)
-        jitter := time.Duration(rand.Int63n(int64(sleep / 2)))
-        time.Sleep(sleep + jitter)
-    }
-
-	return nil, lastErr
+	url := fmt.Sprintf("%s/coaches/%s/availability?days=%d", c.BaseURL, coachID, days)
 
+	var out structs.GetAvailabilityResponse
+	if err := c.get(ctx, url, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
 }
 
-func (c *AvailabilityClient) BlockSlot(coachID string, startTime time.Time, endTime time.Time) (*structs.BlockSlotResponse, error){
+func (c *AvailabilityClient) BlockSlot(ctx context.Context, coachID string, startTime time.Time, endTime time.Time) (*structs.BlockSlotResponse, error) {
 	url := fmt.Sprintf("%s/coaches/%s/block-slot", c.BaseURL, coachID)
 
 	requestBody := structs.BlockSlotRequest{
 		StartTime: startTime,
-		EndTime: endTime,
-	};
-
-	requestBodyBytes, err := json.Marshal(requestBody)
-
-
-    maxAttempts, err := strconv.Atoi(os.Getenv("WEBHOOK_RETRY_ATTEMPTS"))
-	if err != nil {
-		return nil, err
+		EndTime:   endTime,
 	}
 
-    webhookDelay, err := strconv.Atoi(os.Getenv("WEBHOOK_RETRY_DELAY_MS"))
-	if err != nil {
-		return nil, err
-	}
-
-
-	baseDelay := time.Duration(webhookDelay) * time.Millisecond
-
-	var lastErr error
 	var response structs.BlockSlotResponse
-
-	for attempt := range maxAttempts {
-		log.Printf("Attempt No. %d", attempt)
-		resp, err := c.Client.Post(url, "application/json", bytes.NewBuffer(requestBodyBytes))
-		if err != nil {
-			lastErr = err
-		} else {
-			defer resp.Body.Close()
-
-			if resp.StatusCode > 500 {
-				log.Printf("Failed, retrying..")
-			} else if resp.StatusCode >= 400 {
-				return nil, err
-			} else {
-                if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-                    return nil, err
-                }
-				log.Printf("Recieved success!")
-                return &response, nil
-
-			}
-		}
-
-        sleep := baseDelay * (1 << attempt)
-        jitter := time.Duration(rand.Int63n(int64(sleep / 2)))
-        time.Sleep(sleep + jitter)
-
+	if err := c.post(ctx, url, requestBody, &response); err != nil {
+		return nil, err
 	}
-
-	return nil, lastErr
+	return &response, nil
 }
 
-func (c *AvailabilityClient) ReleaseSlot(coachID string, blockID string, startTime time.Time, endTime time.Time) (*structs.ReleaseSlotResponse, error){
+func (c *AvailabilityClient) ReleaseSlot(ctx context.Context, coachID string, blockID string, startTime time.Time, endTime time.Time) (*structs.ReleaseSlotResponse, error) {
 	url := fmt.Sprintf("%s/coaches/%s/release-slot", c.BaseURL, coachID)
 
 	requestBody := structs.ReleaseSlotRequest{
 		BlockId: blockID,
-	};
-
-
-	requestBodyBytes, err := json.Marshal(requestBody)
-
-
-	resp, err := c.Client.Post(url, "application/json", bytes.NewBuffer(requestBodyBytes))
-	if err != nil {
-		return nil, fmt.Errorf("error calling API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
 	}
 
 	var data structs.ReleaseSlotResponse
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := c.post(ctx, url, requestBody, &data); err != nil {
+		return nil, err
 	}
-
 	return &data, nil
-
 }
 
-func (c *AvailabilityClient) GetCoachSettings(coachID string) (*structs.CoachSettingsResponse, error){
+func (c *AvailabilityClient) GetCoachSettings(ctx context.Context, coachID string) (*structs.CoachSettingsResponse, error) {
 	url := fmt.Sprintf("%s/coaches/%s/settings", c.BaseURL, coachID)
 
-	resp, err := c.Client.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("error calling API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
-	}
-
 	var data structs.CoachSettingsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := c.get(ctx, url, &data); err != nil {
+		return nil, err
 	}
-
 	return &data, nil
-
 }
 
 // TODO: Webhook Calendar Update API -> Vague Body
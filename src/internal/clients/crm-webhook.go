@@ -4,16 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"math/rand"
-	"os"
-	"io"
 
 	"bytes"
 	"context"
 	"net/http"
-	"strconv"
 	"time"
 
+	"github.com/transistxr/coach-assignment-server/src/internal/breaker"
+	"github.com/transistxr/coach-assignment-server/src/internal/retry"
 	"github.com/transistxr/coach-assignment-server/src/internal/structs"
 )
 
@@ -23,12 +21,16 @@ import (
 type CRMClient struct {
 	baseURL    string
 	httpClient *http.Client
+	retry      retry.Policy
+	Breaker    *breaker.Breaker
 }
 
-func NewCRMClient(baseURL string) *CRMClient {
+func NewCRMClient(baseURL string, policy retry.Policy, b *breaker.Breaker) *CRMClient {
 	return &CRMClient{
 		baseURL:    baseURL,
 		httpClient: &http.Client{Timeout: 10 * time.Second},
+		retry:      policy,
+		Breaker:    b,
 	}
 }
 
@@ -67,18 +69,26 @@ func (c *CRMClient) SendAppointmentCancelled(ctx context.Context, req *structs.A
 
 func (c *CRMClient) GetContact(ctx context.Context, contactID string) (*structs.Contact, error) {
 	url := fmt.Sprintf("%s/contacts/%s", c.baseURL, contactID)
-	resp, err := c.httpClient.Get(url)
+	resp, err := guarded(ctx, c.Breaker, func() (*http.Response, error) {
+		return c.retry.Do(ctx, func(ctx context.Context) (*http.Response, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return nil, err
+			}
+			return c.httpClient.Do(req)
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
 		return nil, fmt.Errorf("contact %s not found", contactID)
 	}
 
 	var out structs.Contact
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+	if err := decodeResponse(resp, &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
@@ -90,51 +100,25 @@ func (c *CRMClient) postWithIdempotency(ctx context.Context, url string, body in
 		return err
 	}
 
-	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(b))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Idempotency-Key", key)
-
-	maxAttempts, err := strconv.Atoi(os.Getenv("WEBHOOK_RETRY_ATTEMPTS"))
+	resp, err := guarded(ctx, c.Breaker, func() (*http.Response, error) {
+		return c.retry.Do(ctx, func(ctx context.Context) (*http.Response, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Idempotency-Key", key)
+			return c.httpClient.Do(req)
+		})
+	})
 	if err != nil {
+		log.Printf("Request error: %v", err)
 		return err
 	}
 
-	webhookDelay, err := strconv.Atoi(os.Getenv("WEBHOOK_RETRY_DELAY_MS"))
-	if err != nil {
+	if err := decodeResponse(resp, out); err != nil {
 		return err
 	}
-
-	baseDelay := time.Duration(webhookDelay) * time.Millisecond
-	var lastErr error
-	for attempt := 0; attempt < maxAttempts; attempt++ {
-		log.Printf("Attempt No. %d", attempt)
-
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			log.Printf("Request error: %v", err)
-			lastErr = err
-		} else {
-			body, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-
-			if resp.StatusCode >= 500 {
-				log.Printf("Server error, retrying.. Status: %d, Body: %s", resp.StatusCode, string(body))
-			} else if resp.StatusCode >= 400 {
-				return fmt.Errorf("client error: %d, Body: %s", resp.StatusCode, string(body))
-			} else {
-				if err := json.Unmarshal(body, out); err != nil {
-					return fmt.Errorf("failed to decode response: %w", err)
-				}
-				log.Printf("Received success!")
-				return nil
-			}
-		}
-
-		sleep := baseDelay * (1 << attempt)
-		jitter := time.Duration(rand.Int63n(int64(sleep / 2)))
-		log.Printf("Sleeping for %v", sleep+jitter)
-		time.Sleep(sleep + jitter)
-	}
-
-	return lastErr
+	log.Printf("Received success!")
+	return nil
 }
@@ -0,0 +1,46 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/breaker"
+)
+
+// decodeResponse closes resp.Body after decoding it into out. Any status of
+// 400 or above is returned as an error carrying the response body.
+func decodeResponse(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("unexpected status: %s, Body: %s", resp.Status, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// guarded runs call through b. Transport errors and 5xx responses count as
+// downstream failures; a call abandoned because ctx ended counts as neither.
+func guarded(ctx context.Context, b *breaker.Breaker, call func() (*http.Response, error)) (*http.Response, error) {
+	if err := b.Allow(); err != nil {
+		return nil, err
+	}
+
+	resp, err := call()
+	switch {
+	case err != nil && ctx.Err() != nil:
+		b.Ignore()
+	case err != nil || resp.StatusCode >= 500:
+		b.Failure()
+	default:
+		b.Success()
+	}
+	return resp, err
+}
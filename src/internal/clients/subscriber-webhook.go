@@ -0,0 +1,72 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/retry"
+	"github.com/transistxr/coach-assignment-server/src/internal/signing"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// SubscriberClient delivers outbound events to the URLs registered in
+// webhook_subscriptions. Each attempt is signed with the subscription secret
+// and retried with exponential backoff and jitter.
+type SubscriberClient struct {
+	httpClient *http.Client
+	retry      retry.Policy
+}
+
+func NewSubscriberClient(policy retry.Policy) *SubscriberClient {
+	return &SubscriberClient{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		retry:      policy,
+	}
+}
+
+// Deliver posts event to url. The returned result reports how many attempts
+// were made and the last HTTP status seen, also when err is non-nil.
+func (c *SubscriberClient) Deliver(ctx context.Context, url string, secret string, deliveryID string, event *structs.OutboundEvent) (*structs.DeliveryResult, error) {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &structs.DeliveryResult{}
+	resp, err := c.retry.Do(ctx, func(ctx context.Context) (*http.Response, error) {
+		result.Attempts++
+		log.Printf("Delivering %s to %s, attempt No. %d", event.Type, url, result.Attempts)
+
+		// Sign every attempt so the timestamp stays inside the receiver's
+		// replay window across backoff sleeps.
+		ts := time.Now().Unix()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Event-Type", event.Type)
+		req.Header.Set("X-Idempotency-Key", deliveryID)
+		req.Header.Set(signing.TimestampHeader, strconv.FormatInt(ts, 10))
+		req.Header.Set(signing.SignatureHeader, signing.Sign(secret, ts, b))
+		return c.httpClient.Do(req)
+	})
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	result.ResponseStatus = resp.StatusCode
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return result, fmt.Errorf("subscriber rejected event: %d, Body: %s", resp.StatusCode, string(body))
+	}
+	return result, nil
+}
@@ -2,7 +2,6 @@ package db
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"log"
 	"os"
@@ -17,7 +16,7 @@ type RedisClient struct {
 	Client *redis.Client
 }
 
-func InitRDB(db *sql.DB) *RedisClient {
+func InitRDB() *RedisClient {
 
 	log.Println("Initializing Redis Database Connection")
 	rdb := redis.NewClient(&redis.Options{
@@ -33,54 +32,10 @@ func InitRDB(db *sql.DB) *RedisClient {
 
 	log.Println("Connected to Redis")
 
-	log.Println("Loading API Keys into Redis..")
-
-	rows, err := db.Query(`SELECT key_hash, name, rate_limit FROM api_keys`)
-	if err != nil {
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var key_hash string
-		var name string
-		var rate_limit float64
-
-		if err := rows.Scan(&key_hash, &name, &rate_limit); err != nil {
-			log.Fatal(err)
-		}
-
-		switch name{
-			case "Test API Key":
-			 rdb.Set(ctx, "TEST_API_KEY",key_hash, 0)
-			 rdb.Set(ctx, "TEST_ENV_RATE_LIMIT",rate_limit, 0)
-
-			case "Development Key":
-			 rdb.Set(ctx, "DEVELOPMENT_KEY",key_hash, 0)
-			 rdb.Set(ctx, "DEV_ENV_RATE_LIMIT",rate_limit, 0)
-
-			case "Production Key":
-			 rdb.Set(ctx, "PRODUCTION_KEY",key_hash, 0)
-			 rdb.Set(ctx, "PROD_ENV_RATE_LIMIT",rate_limit, 0)
-
-		}
-	}
-
 	return &RedisClient{Client: rdb}
 
 }
 
-func GetValueString(rdb *RedisClient, key string) string {
-
-	log.Printf("Reading Redis key: %s \n", key)
-
-    val, err := rdb.Client.Get(ctx, key).Result()
-    if err != nil {
-        panic(err)
-    }
-
-	return val
-}
-
 func SetIdempotencyKey(rdb *RedisClient, idemKey string, response any) error {
 
 	log.Printf("Setting idempotency key %s with response: %s \n", idemKey, response)
@@ -108,3 +63,21 @@ func CheckIdempotency(rdb *RedisClient, idemKey string, response any) (bool, any
 	}
 	return true, response
 }
+
+// MarkSignatureSeen records a webhook signature for ttl and reports whether it
+// was already recorded, i.e. whether the request is a replay.
+func (rdb *RedisClient) MarkSignatureSeen(signature string, ttl time.Duration) (bool, error) {
+
+	ok, err := rdb.Client.SetNX(ctx, "webhook-signature:"+signature, 1, ttl).Result()
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+// ReleaseSignature forgets a signature recorded by MarkSignatureSeen, so the
+// same request can be retried.
+func (rdb *RedisClient) ReleaseSignature(signature string) error {
+
+	return rdb.Client.Del(ctx, "webhook-signature:"+signature).Err()
+}
@@ -0,0 +1,213 @@
+// Package redistest runs an in-memory stand-in for Redis that understands the
+// handful of commands the server uses, so packages built on db.RedisClient
+// can be tested without a Redis instance.
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/transistxr/coach-assignment-server/src/internal/db"
+)
+
+// Server is the in-memory store behind a client returned by New.
+type Server struct {
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+}
+
+// New starts a server for the duration of the test and returns a client
+// connected to it.
+func New(t testing.TB) (*db.RedisClient, *Server) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("redistest: listen: %v", err)
+	}
+	s := &Server{values: map[string]string{}, expires: map[string]time.Time{}}
+	go s.serve(ln)
+
+	client := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), Protocol: 2})
+	t.Cleanup(func() {
+		client.Close()
+		ln.Close()
+	})
+	return &db.RedisClient{Client: client}, s
+}
+
+// Has reports whether key holds an unexpired value.
+func (s *Server) Has(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.get(key)
+	return ok
+}
+
+func (s *Server) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	var queued [][]string
+	inMulti := false
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(args[0]); {
+		case cmd == "MULTI":
+			inMulti, queued = true, nil
+			w.WriteString("+OK\r\n")
+		case cmd == "EXEC":
+			fmt.Fprintf(w, "*%d\r\n", len(queued))
+			for _, q := range queued {
+				w.WriteString(s.exec(q))
+			}
+			inMulti, queued = false, nil
+		case inMulti:
+			queued = append(queued, args)
+			w.WriteString("+QUEUED\r\n")
+		default:
+			w.WriteString(s.exec(args))
+		}
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func bulk(v string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+}
+
+const nilBulk = "$-1\r\n"
+
+// get must be called with s.mu held.
+func (s *Server) get(key string) (string, bool) {
+	if exp, ok := s.expires[key]; ok && !time.Now().Before(exp) {
+		delete(s.values, key)
+		delete(s.expires, key)
+	}
+	v, ok := s.values[key]
+	return v, ok
+}
+
+func (s *Server) exec(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		if v, ok := s.get(args[1]); ok {
+			return bulk(v)
+		}
+		return nilBulk
+	case "SET":
+		key, value := args[1], args[2]
+		var ttl time.Duration
+		nx := false
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "EX", "PX":
+				n, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(n) * time.Second
+				if strings.ToUpper(args[i]) == "PX" {
+					ttl = time.Duration(n) * time.Millisecond
+				}
+				i++
+			}
+		}
+		if _, exists := s.get(key); exists && nx {
+			return nilBulk
+		}
+		s.values[key] = value
+		delete(s.expires, key)
+		if ttl > 0 {
+			s.expires[key] = time.Now().Add(ttl)
+		}
+		return "+OK\r\n"
+	case "DEL":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := s.get(key); ok {
+				delete(s.values, key)
+				delete(s.expires, key)
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case "INCR":
+		v, _ := s.get(args[1])
+		n, _ := strconv.Atoi(v)
+		n++
+		s.values[args[1]] = strconv.Itoa(n)
+		return fmt.Sprintf(":%d\r\n", n)
+	case "EXPIREAT":
+		if _, ok := s.get(args[1]); !ok {
+			return ":0\r\n"
+		}
+		at, _ := strconv.ParseInt(args[2], 10, 64)
+		s.expires[args[1]] = time.Unix(at, 0)
+		return ":1\r\n"
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
@@ -0,0 +1,131 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/transistxr/coach-assignment-server/src/internal/clients"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// Publisher fans booking events out to every active subscription that asked
+// for them. Each delivery is recorded in webhook_deliveries before it is sent
+// in the background, so callers never wait on subscribers and a delivery cut
+// short by a restart is re-sent from its row by jobs.DeliveryRedriver.
+type Publisher struct {
+	DB     *sql.DB
+	Client *clients.SubscriberClient
+}
+
+func NewPublisher(sqlDB *sql.DB, client *clients.SubscriberClient) *Publisher {
+	return &Publisher{
+		DB:     sqlDB,
+		Client: client,
+	}
+}
+
+// Publish records a delivery for each matching subscription of tenantID and
+// starts sending them. Failures are logged and kept in the delivery log; they are
+// never returned to the caller.
+func (p *Publisher) Publish(ctx context.Context, tenantID string, eventType string, data any) {
+	event := &structs.OutboundEvent{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Publisher: failed to marshal %s event: %v", eventType, err)
+		return
+	}
+
+	// last_attempt marks the deliveries as being sent, so the re-driver
+	// leaves them alone unless they are still unsent an interval later.
+	rows, err := p.DB.QueryContext(ctx, `
+INSERT INTO webhook_deliveries (id, subscription_id, event_id, event_type, payload, status, last_attempt)
+SELECT uuid_generate_v4()::text, id, $3, $1, $4, 'pending', NOW() FROM webhook_subscriptions
+WHERE active = true AND event_types ? $1 AND tenant_id = $2
+RETURNING id`, eventType, tenantID, event.ID, payload)
+	if err != nil {
+		log.Printf("Publisher: failed to record deliveries for %s: %v", eventType, err)
+		return
+	}
+	defer rows.Close()
+
+	var deliveryIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("Publisher: scan delivery id: %v", err)
+			return
+		}
+		deliveryIDs = append(deliveryIDs, id)
+	}
+
+	for _, id := range deliveryIDs {
+		// Deliveries outlive the request that triggered them.
+		go func(id string) {
+			if err := p.Deliver(context.Background(), id); err != nil {
+				log.Printf("Publisher: delivery %s failed: %v", id, err)
+			}
+		}(id)
+	}
+}
+
+// Deliver sends a recorded delivery that is pending or failed to its
+// subscription and records the outcome on the row. Deliveries of a
+// subscription that was deleted or deactivated are skipped.
+func (p *Publisher) Deliver(ctx context.Context, deliveryID string) error {
+	var url, secret string
+	var payload []byte
+	err := p.DB.QueryRowContext(ctx, `
+SELECT s.url, s.secret, d.payload
+FROM webhook_deliveries d
+JOIN webhook_subscriptions s ON s.id = d.subscription_id
+WHERE d.id = $1 AND d.status IN ('pending', 'failed') AND s.active`, deliveryID).Scan(&url, &secret, &payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var event structs.OutboundEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return err
+	}
+
+	result, err := p.Client.Deliver(ctx, url, secret, deliveryID, &event)
+
+	var attempts int
+	var responseStatus sql.NullInt64
+	if result != nil {
+		attempts = result.Attempts
+		if result.ResponseStatus != 0 {
+			responseStatus = sql.NullInt64{Int64: int64(result.ResponseStatus), Valid: true}
+		}
+	}
+
+	if err != nil {
+		_, dbErr := p.DB.ExecContext(ctx, `
+UPDATE webhook_deliveries SET status = 'failed', attempts = attempts + $2, response_status = $3, error_message = $4, last_attempt = NOW()
+WHERE id = $1`, deliveryID, attempts, responseStatus, err.Error())
+		if dbErr != nil {
+			log.Printf("Publisher: failed to update delivery %s: %v", deliveryID, dbErr)
+		}
+		return err
+	}
+
+	_, err = p.DB.ExecContext(ctx, `
+UPDATE webhook_deliveries SET status = 'delivered', attempts = attempts + $2, response_status = $3, error_message = NULL,
+last_attempt = NOW(), delivered_at = NOW()
+WHERE id = $1`, deliveryID, attempts, responseStatus)
+	return err
+}
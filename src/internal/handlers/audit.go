@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/auth"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// AuditHandler exposes the audit log of the caller's tenant.
+type AuditHandler struct {
+	Deps *HandlerDeps
+}
+
+// ListAudit handles GET /api/audit, newest first. `actor_id`, `action`,
+// `entity_type`, `entity_id` and `request_id` filter by exact match, `since`
+// and `until` (RFC 3339) bound created_at and `limit` caps the page
+// (default 100).
+func (h *AuditHandler) ListAudit(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	filter := func(name string) sql.NullString {
+		v := q.Get(name)
+		return sql.NullString{String: v, Valid: v != ""}
+	}
+
+	var bounds [2]sql.NullTime
+	for i, name := range []string{"since", "until"} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", name+" must be an RFC 3339 timestamp", err)
+			return
+		}
+		bounds[i] = sql.NullTime{Time: t, Valid: true}
+	}
+
+	rows, err := h.Deps.DB.QueryContext(ctx, `
+SELECT id, actor_type, COALESCE(actor_id, ''), action, entity_type, entity_id,
+       before_state, after_state, COALESCE(request_id, ''), created_at
+FROM audit_log
+WHERE tenant_id = $1
+  AND ($2::varchar IS NULL OR actor_id = $2)
+  AND ($3::varchar IS NULL OR action = $3)
+  AND ($4::varchar IS NULL OR entity_type = $4)
+  AND ($5::varchar IS NULL OR entity_id = $5)
+  AND ($6::varchar IS NULL OR request_id = $6)
+  AND ($7::timestamptz IS NULL OR created_at >= $7)
+  AND ($8::timestamptz IS NULL OR created_at < $8)
+ORDER BY created_at DESC
+LIMIT $9`, auth.TenantFrom(ctx), filter("actor_id"), filter("action"), filter("entity_type"),
+		filter("entity_id"), filter("request_id"), bounds[0], bounds[1], parseLimit(r, 100, 1000))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer rows.Close()
+
+	resp := structs.AuditListResponse{Entries: []structs.AuditEntry{}}
+	for rows.Next() {
+		var e structs.AuditEntry
+		if err := rows.Scan(&e.ID, &e.ActorType, &e.ActorID, &e.Action, &e.EntityType, &e.EntityID,
+			&e.Before, &e.After, &e.RequestID, &e.CreatedAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+		resp.Entries = append(resp.Entries, e)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
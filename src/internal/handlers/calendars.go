@@ -0,0 +1,492 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/transistxr/coach-assignment-server/src/internal/audit"
+	"github.com/transistxr/coach-assignment-server/src/internal/auth"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+const (
+	// slotGranularityMinutes is the length of a row in coach_slots; calendar
+	// durations and intervals must be whole multiples of it.
+	slotGranularityMinutes = 15
+	maxSlotDurationMinutes = 8 * 60
+
+	// groupCalendarType is the calendar type whose sessions seat up to
+	// capacity attendees with the same coach.
+	groupCalendarType = "group"
+	maxCapacity       = 100
+)
+
+// CalendarHandler manages calendars (appointment types) and which coaches
+// are on each calendar's team. Like coaches, calendars are deactivated rather
+// than deleted because appointments keep referencing them.
+type CalendarHandler struct {
+	Deps *HandlerDeps
+}
+
+// calendarColumns matches scanCalendar. It is also used in RETURNING, so
+// the team subquery refers to the table by name.
+const calendarColumns = `id, name, calendar_type, slot_duration, slot_interval, capacity, active,
+ARRAY(SELECT cc.coach_id FROM coach_calendars cc WHERE cc.calendar_id = calendars.id ORDER BY cc.coach_id),
+created_at, updated_at`
+
+func scanCalendar(row rowScanner) (*structs.Calendar, error) {
+	var c structs.Calendar
+	var coachIDs pq.StringArray
+	var updatedAt sql.NullTime
+	err := row.Scan(&c.ID, &c.Name, &c.CalendarType, &c.SlotDuration, &c.SlotInterval, &c.Capacity, &c.Active,
+		&coachIDs, &c.CreatedAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	c.CoachIDs = []string(coachIDs)
+	if c.CoachIDs == nil {
+		c.CoachIDs = []string{}
+	}
+	c.UpdatedAt = nullTimePtr(updatedAt)
+	return &c, nil
+}
+
+type calendarParams struct {
+	name, calendarType         sql.NullString
+	slotDuration, slotInterval sql.NullInt64
+	capacity                   sql.NullInt64
+	active                     sql.NullBool
+}
+
+func validSlotMinutes(m int) bool {
+	return m > 0 && m <= maxSlotDurationMinutes && m%slotGranularityMinutes == 0
+}
+
+func parseCalendarRequest(req *structs.CalendarRequest) (*calendarParams, error) {
+	var p calendarParams
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, invalidEvent("name must not be empty", nil)
+		}
+		p.name = sql.NullString{String: name, Valid: true}
+	}
+	if req.CalendarType != nil {
+		calendarType := strings.TrimSpace(*req.CalendarType)
+		if calendarType == "" {
+			return nil, invalidEvent("calendar_type must not be empty", nil)
+		}
+		p.calendarType = sql.NullString{String: calendarType, Valid: true}
+	}
+	if req.SlotDuration != nil {
+		if !validSlotMinutes(*req.SlotDuration) {
+			return nil, invalidEvent("slot_duration must be a multiple of 15 minutes, at most 480", nil)
+		}
+		p.slotDuration = sql.NullInt64{Int64: int64(*req.SlotDuration), Valid: true}
+	}
+	if req.SlotInterval != nil {
+		if !validSlotMinutes(*req.SlotInterval) {
+			return nil, invalidEvent("slot_interval must be a multiple of 15 minutes, at most 480", nil)
+		}
+		p.slotInterval = sql.NullInt64{Int64: int64(*req.SlotInterval), Valid: true}
+	}
+	if req.Capacity != nil {
+		if *req.Capacity < 1 || *req.Capacity > maxCapacity {
+			return nil, invalidEvent("capacity must be between 1 and 100", nil)
+		}
+		p.capacity = sql.NullInt64{Int64: int64(*req.Capacity), Valid: true}
+	}
+	if req.Active != nil {
+		p.active = sql.NullBool{Bool: *req.Active, Valid: true}
+	}
+	return &p, nil
+}
+
+// checkCapacity validates the calendar type and capacity a calendar ends up
+// with: group calendars need more than one seat, others exactly one.
+func checkCapacity(calendarType string, capacity int) error {
+	if calendarType == groupCalendarType && capacity < 2 {
+		return invalidEvent("group calendars need a capacity of at least 2", nil)
+	}
+	if calendarType != groupCalendarType && capacity != 1 {
+		return invalidEvent("only group calendars can have a capacity above 1", nil)
+	}
+	return nil
+}
+
+// writeCalendarError reports a *webhookError as is; anything else is a
+// database failure.
+func writeCalendarError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Calendar not found", nil)
+		return
+	}
+	var whErr *webhookError
+	if !errors.As(err, &whErr) {
+		whErr = databaseFailure(err)
+	}
+	writeError(w, whErr.status, whErr.code, whErr.message, whErr.err)
+}
+
+// CreateCalendar handles POST /api/calendars. name is required; other fields
+// default to the column defaults.
+func (h *CalendarHandler) CreateCalendar(w http.ResponseWriter, r *http.Request) {
+
+	log.Println("Received POST Request: /api/calendars")
+	ctx := r.Context()
+
+	var req structs.CalendarRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", err)
+		return
+	}
+	if req.Name == nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name is required", nil)
+		return
+	}
+
+	p, err := parseCalendarRequest(&req)
+	if err != nil {
+		writeCalendarError(w, err)
+		return
+	}
+	calendarType, capacity := "standard", int64(1)
+	if p.calendarType.Valid {
+		calendarType = p.calendarType.String
+	}
+	if p.capacity.Valid {
+		capacity = p.capacity.Int64
+	}
+	if err := checkCapacity(calendarType, int(capacity)); err != nil {
+		writeCalendarError(w, err)
+		return
+	}
+
+	calendar, err := scanCalendar(h.Deps.DB.QueryRowContext(ctx, `
+INSERT INTO calendars (id, tenant_id, name, calendar_type, slot_duration, slot_interval, active, capacity)
+VALUES ($1, $2, $3, $4, COALESCE($5, 30), COALESCE($6, 15), COALESCE($7, true), $8)
+RETURNING `+calendarColumns, uuid.NewString(), auth.TenantFrom(ctx), p.name, calendarType,
+		p.slotDuration, p.slotInterval, p.active, capacity))
+	if err != nil {
+		writeCalendarError(w, err)
+		return
+	}
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionCalendarCreated,
+		EntityType: "calendar",
+		EntityID:   calendar.ID,
+		After:      calendar,
+	})
+
+	writeJSON(w, http.StatusCreated, structs.CalendarResponse{Calendar: *calendar})
+}
+
+// ListCalendars handles GET /api/calendars. Inactive calendars are included
+// only with `include_inactive=true`.
+func (h *CalendarHandler) ListCalendars(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	rows, err := h.Deps.DB.QueryContext(ctx, `
+SELECT `+calendarColumns+` FROM calendars
+WHERE tenant_id = $1 AND (active OR $2)
+ORDER BY name`, auth.TenantFrom(ctx), r.URL.Query().Get("include_inactive") == "true")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer rows.Close()
+
+	resp := structs.CalendarListResponse{Calendars: []structs.Calendar{}}
+	for rows.Next() {
+		calendar, err := scanCalendar(rows)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+		resp.Calendars = append(resp.Calendars, *calendar)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GetCalendar handles GET /api/calendars/{id}.
+func (h *CalendarHandler) GetCalendar(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	calendar, err := scanCalendar(h.Deps.DB.QueryRowContext(ctx, `SELECT `+calendarColumns+` FROM calendars
+WHERE id = $1 AND tenant_id = $2`, chi.URLParam(r, "id"), auth.TenantFrom(ctx)))
+	if err != nil {
+		writeCalendarError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, structs.CalendarResponse{Calendar: *calendar})
+}
+
+// UpdateCalendar handles PATCH /api/calendars/{id}. A new slot_duration only
+// applies to new bookings: future scheduled appointments keep their end time
+// and the slots they hold, and are listed in `preserved_appointments` so the
+// caller can reschedule them if needed. A longer duration is refused with 409
+// while any of them could not be stretched to it in place. Lowering a group
+// calendar's capacity likewise keeps the attendees sessions already have.
+func (h *CalendarHandler) UpdateCalendar(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+	calendarID := chi.URLParam(r, "id")
+
+	var req structs.CalendarRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", err)
+		return
+	}
+
+	p, err := parseCalendarRequest(&req)
+	if err != nil {
+		writeCalendarError(w, err)
+		return
+	}
+
+	tx, err := h.Deps.DB.BeginTx(ctx, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := scanCalendar(tx.QueryRowContext(ctx, `SELECT `+calendarColumns+` FROM calendars
+WHERE id = $1 AND tenant_id = $2 FOR UPDATE`, calendarID, auth.TenantFrom(ctx)))
+	if err != nil {
+		writeCalendarError(w, err)
+		return
+	}
+
+	calendarType, capacity := before.CalendarType, before.Capacity
+	if p.calendarType.Valid {
+		calendarType = p.calendarType.String
+	}
+	if p.capacity.Valid {
+		capacity = int(p.capacity.Int64)
+	}
+	if err := checkCapacity(calendarType, capacity); err != nil {
+		writeCalendarError(w, err)
+		return
+	}
+
+	after, err := scanCalendar(tx.QueryRowContext(ctx, `
+UPDATE calendars SET
+  name = COALESCE($2, name),
+  calendar_type = COALESCE($3, calendar_type),
+  slot_duration = COALESCE($4, slot_duration),
+  slot_interval = COALESCE($5, slot_interval),
+  active = COALESCE($6, active),
+  capacity = COALESCE($7, capacity),
+  updated_at = NOW()
+WHERE id = $1
+RETURNING `+calendarColumns, calendarID, p.name, p.calendarType, p.slotDuration, p.slotInterval, p.active, p.capacity))
+	if err != nil {
+		writeCalendarError(w, err)
+		return
+	}
+
+	if after.SlotDuration > before.SlotDuration {
+		conflicts, err := durationConflicts(ctx, tx, calendarID, after.SlotDuration)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+		if len(conflicts) > 0 {
+			writeJSON(w, http.StatusConflict, structs.CalendarConflictResponse{
+				BaseResponse: structs.BaseResponse{
+					Error:   "CONFLICT",
+					Message: fmt.Sprintf("%d future appointments can't be lengthened to %d minutes; reschedule or cancel them first", len(conflicts), after.SlotDuration),
+				},
+				ConflictingAppointments: conflicts,
+			})
+			return
+		}
+	}
+
+	var preserved []string
+	if after.SlotDuration != before.SlotDuration {
+		rows, err := tx.QueryContext(ctx, `
+SELECT id FROM coach_appointments
+WHERE calendar_id = $1 AND status = 'scheduled' AND start_time > NOW()
+ORDER BY start_time`, calendarID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+				return
+			}
+			preserved = append(preserved, id)
+		}
+		if err := rows.Err(); err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+		if len(preserved) > 0 {
+			log.Printf("UpdateCalendar: calendar %s duration %d -> %d min; %d future appointments keep their original length",
+				calendarID, before.SlotDuration, after.SlotDuration, len(preserved))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	action := audit.ActionCalendarUpdated
+	if before.Active && !after.Active {
+		action = audit.ActionCalendarDeactivated
+	}
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     action,
+		EntityType: "calendar",
+		EntityID:   calendarID,
+		Before:     before,
+		After:      after,
+	})
+
+	writeJSON(w, http.StatusOK, structs.CalendarResponse{Calendar: *after, PreservedAppointments: preserved})
+}
+
+// durationConflicts returns the calendar's future scheduled appointments
+// that, lengthened to duration minutes, would overlap the coach's next
+// booking or end after the coach's working hours on their day.
+func durationConflicts(ctx context.Context, tx *sql.Tx, calendarID string, duration int) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+SELECT ca.id FROM coach_appointments ca
+JOIN coaches c ON c.id = ca.coach_id
+WHERE ca.calendar_id = $1 AND ca.status = 'scheduled' AND ca.start_time > NOW()
+  AND (
+    (ca.start_time + make_interval(mins => $2)) AT TIME ZONE c.timezone
+      > (ca.start_time AT TIME ZONE c.timezone)::date + c.working_hours_end
+    OR EXISTS (
+      SELECT 1 FROM coach_appointments nxt
+      WHERE nxt.coach_id = ca.coach_id AND nxt.status = 'scheduled'
+        AND nxt.start_time >= ca.end_time
+        AND nxt.start_time < ca.start_time + make_interval(mins => $2)
+    )
+  )
+ORDER BY ca.start_time, ca.id`, calendarID, duration)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DeactivateCalendar handles DELETE /api/calendars/{id}. Existing
+// appointments are kept; the calendar just stops taking bookings.
+func (h *CalendarHandler) DeactivateCalendar(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+	calendarID := chi.URLParam(r, "id")
+
+	calendar, err := scanCalendar(h.Deps.DB.QueryRowContext(ctx, `
+UPDATE calendars SET active = false, updated_at = NOW()
+WHERE id = $1 AND tenant_id = $2
+RETURNING `+calendarColumns, calendarID, auth.TenantFrom(ctx)))
+	if err != nil {
+		writeCalendarError(w, err)
+		return
+	}
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionCalendarDeactivated,
+		EntityType: "calendar",
+		EntityID:   calendarID,
+		After:      calendar,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddCoach handles PUT /api/calendars/{id}/coaches/{coachId}. Adding a coach
+// who is already on the team is a no-op.
+func (h *CalendarHandler) AddCoach(w http.ResponseWriter, r *http.Request) {
+	h.changeTeam(w, r, true)
+}
+
+// RemoveCoach handles DELETE /api/calendars/{id}/coaches/{coachId}. The
+// coach's existing appointments on the calendar are kept.
+func (h *CalendarHandler) RemoveCoach(w http.ResponseWriter, r *http.Request) {
+	h.changeTeam(w, r, false)
+}
+
+func (h *CalendarHandler) changeTeam(w http.ResponseWriter, r *http.Request, add bool) {
+
+	ctx := r.Context()
+	tenantID := auth.TenantFrom(ctx)
+	calendarID := chi.URLParam(r, "id")
+	coachID := chi.URLParam(r, "coachId")
+
+	var calendarFound, coachFound bool
+	err := h.Deps.DB.QueryRowContext(ctx, `
+SELECT
+  EXISTS (SELECT 1 FROM calendars WHERE id = $1 AND tenant_id = $3),
+  EXISTS (SELECT 1 FROM coaches WHERE id = $2 AND tenant_id = $3)`,
+		calendarID, coachID, tenantID).Scan(&calendarFound, &coachFound)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	if !calendarFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Calendar not found", nil)
+		return
+	}
+	if !coachFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Coach not found", nil)
+		return
+	}
+
+	query := `INSERT INTO coach_calendars (coach_id, calendar_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	action := audit.ActionCalendarCoachAdded
+	if !add {
+		query = `DELETE FROM coach_calendars WHERE coach_id = $1 AND calendar_id = $2`
+		action = audit.ActionCalendarCoachRemoved
+	}
+
+	res, err := h.Deps.DB.ExecContext(ctx, query, coachID, calendarID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	if n, _ := res.RowsAffected(); n > 0 {
+		h.Deps.Audit.Record(ctx, audit.Entry{
+			Action:     action,
+			EntityType: "calendar",
+			EntityID:   calendarID,
+			After:      map[string]string{"coach_id": coachID},
+		})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
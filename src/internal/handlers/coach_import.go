@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/transistxr/coach-assignment-server/src/internal/audit"
+	"github.com/transistxr/coach-assignment-server/src/internal/auth"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+const (
+	maxCoachImportBytes = 5 << 20
+	maxCoachImportRows  = 5000
+	// calendarSeparator splits the calendar ids in the calendars column.
+	calendarSeparator = ";"
+)
+
+// coachCSVColumns is the export header and the set of columns import accepts.
+// Only name and email are required on import.
+var coachCSVColumns = []string{
+	"name", "email", "score", "max_daily_appointments",
+	"working_hours_start", "working_hours_end", "timezone", "calendars",
+}
+
+// coachImportRow is a validated CSV record ready to upsert.
+type coachImportRow struct {
+	line      int
+	params    *coachParams
+	existing  bool
+	calendars []string
+	// setCalendars is false when the file has no calendars column, which
+	// leaves memberships untouched.
+	setCalendars bool
+}
+
+// ImportCoaches handles POST /api/admin/coaches/import. The body is CSV with
+// a header naming columns from coachCSVColumns. Rows are upserted by email;
+// empty cells keep the coach's current value (or the default for new
+// coaches) and a calendars cell replaces the coach's memberships. Nothing is
+// written if any row is invalid, and `dry_run=true` only reports what would
+// happen.
+func (h *CoachHandler) ImportCoaches(w http.ResponseWriter, r *http.Request) {
+
+	log.Println("Received POST Request: /api/admin/coaches/import")
+	ctx := r.Context()
+	tenantID := auth.TenantFrom(ctx)
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	reader := csv.NewReader(http.MaxBytesReader(w, r.Body, maxCoachImportBytes))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "CSV header row is required", err)
+		return
+	}
+	reader.FieldsPerRecord = len(header)
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(coachCSVColumns, name) {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("unknown column %q", name), nil)
+			return
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name and email columns are required", nil)
+		return
+	}
+	if _, ok := columns["email"]; !ok {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name and email columns are required", nil)
+		return
+	}
+	_, hasCalendars := columns["calendars"]
+
+	existingEmails, err := h.tenantSet(r, `SELECT email FROM coaches WHERE tenant_id = $1`, tenantID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	calendarIDs, err := h.tenantSet(r, `SELECT id FROM calendars WHERE tenant_id = $1`, tenantID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	resp := structs.CoachImportResponse{DryRun: dryRun, Rows: []structs.CoachImportRow{}}
+	var valid []coachImportRow
+	seen := map[string]int{}
+	invalid := 0
+
+	for count := 1; ; count++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Unable to read CSV", err)
+				return
+			}
+			resp.Rows = append(resp.Rows, structs.CoachImportRow{Line: parseErr.StartLine, Errors: []string{parseErr.Err.Error()}})
+			invalid++
+			continue
+		}
+		if count > maxCoachImportRows {
+			writeError(w, http.StatusRequestEntityTooLarge, "VALIDATION_ERROR",
+				fmt.Sprintf("at most %d rows can be imported at once", maxCoachImportRows), nil)
+			return
+		}
+
+		cell := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := structs.CoachImportRow{Line: line, Email: cell("email")}
+		req := structs.CoachRequest{}
+		name, email := cell("name"), cell("email")
+		req.Name, req.Email = &name, &email
+
+		if v := cell("score"); v != "" {
+			score, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				row.Errors = append(row.Errors, "score must be a number")
+			}
+			req.Score = &score
+		}
+		if v := cell("max_daily_appointments"); v != "" {
+			maxDaily, err := strconv.Atoi(v)
+			if err != nil {
+				row.Errors = append(row.Errors, "max_daily_appointments must be a whole number")
+			}
+			req.MaxDailyAppointments = &maxDaily
+		}
+		if start, end, tz := cell("working_hours_start"), cell("working_hours_end"), cell("timezone"); start != "" || end != "" || tz != "" {
+			req.WorkingHours = &structs.WorkingHours{Start: start, End: end, Timezone: tz}
+		}
+
+		params, err := parseCoachRequest(&req)
+		if err != nil {
+			var whErr *webhookError
+			if errors.As(err, &whErr) {
+				row.Errors = append(row.Errors, whErr.message)
+			} else {
+				row.Errors = append(row.Errors, err.Error())
+			}
+		}
+
+		var calendars []string
+		for _, id := range strings.Split(cell("calendars"), calendarSeparator) {
+			if id = strings.TrimSpace(id); id == "" {
+				continue
+			}
+			if !calendarIDs[id] {
+				row.Errors = append(row.Errors, fmt.Sprintf("unknown calendar %q", id))
+				continue
+			}
+			calendars = append(calendars, id)
+		}
+
+		if first, ok := seen[email]; ok && email != "" {
+			row.Errors = append(row.Errors, fmt.Sprintf("duplicate of line %d", first))
+		}
+		seen[email] = line
+
+		if len(row.Errors) > 0 {
+			invalid++
+			resp.Rows = append(resp.Rows, row)
+			continue
+		}
+
+		existing := existingEmails[email]
+		row.Action = "create"
+		if existing {
+			row.Action = "update"
+			resp.Updated++
+		} else {
+			resp.Created++
+		}
+		resp.Rows = append(resp.Rows, row)
+		valid = append(valid, coachImportRow{
+			line:         line,
+			params:       params,
+			existing:     existing,
+			calendars:    calendars,
+			setCalendars: hasCalendars,
+		})
+	}
+
+	if invalid > 0 {
+		resp.Created, resp.Updated = 0, 0
+		resp.Error = "VALIDATION_ERROR"
+		resp.Message = fmt.Sprintf("%d rows have errors; nothing was imported", invalid)
+		if dryRun {
+			resp.Message = fmt.Sprintf("%d rows have errors", invalid)
+		}
+		writeJSON(w, http.StatusUnprocessableEntity, resp)
+		return
+	}
+	if dryRun {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	tx, err := h.Deps.DB.BeginTx(ctx, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer tx.Rollback()
+
+	var written []*structs.CoachProfile
+	for _, row := range valid {
+		p := row.params
+
+		var previous sql.NullFloat64
+		if row.existing {
+			err := tx.QueryRowContext(ctx, `SELECT score FROM coaches WHERE tenant_id = $1 AND email = $2 FOR UPDATE`,
+				tenantID, p.email).Scan(&previous)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+					fmt.Sprintf("Import failed at line %d", row.line), err)
+				return
+			}
+		}
+
+		coach, err := scanCoach(tx.QueryRowContext(ctx, `
+INSERT INTO coaches (id, tenant_id, name, email, score, max_daily_appointments,
+  working_hours_start, working_hours_end, timezone)
+VALUES ($1, $2, $3, $4, COALESCE($5, 0.0), COALESCE($6, 10),
+  COALESCE($7::time, '09:00'), COALESCE($8::time, '17:00'), COALESCE($9, 'America/New_York'))
+ON CONFLICT (tenant_id, email) DO UPDATE SET
+  name = EXCLUDED.name,
+  score = COALESCE($5, coaches.score),
+  max_daily_appointments = COALESCE($6, coaches.max_daily_appointments),
+  working_hours_start = COALESCE($7::time, coaches.working_hours_start),
+  working_hours_end = COALESCE($8::time, coaches.working_hours_end),
+  timezone = COALESCE($9, coaches.timezone),
+  updated_at = NOW()
+RETURNING `+coachColumns, uuid.NewString(), tenantID, p.name, p.email, p.score, p.maxDaily,
+			p.start, p.end, p.timezone))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+				fmt.Sprintf("Import failed at line %d", row.line), err)
+			return
+		}
+
+		if !row.existing || (previous.Valid && previous.Float64 != coach.Score) {
+			var prev *float64
+			if previous.Valid {
+				prev = &previous.Float64
+			}
+			err = recordScoreChange(ctx, tx, tenantID, coach.ID, prev, coach.Score, scoreSourceImport, "")
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+					fmt.Sprintf("Import failed at line %d", row.line), err)
+				return
+			}
+		}
+
+		if row.setCalendars {
+			_, err = tx.ExecContext(ctx, `DELETE FROM coach_calendars WHERE coach_id = $1 AND calendar_id <> ALL($2)`,
+				coach.ID, pq.Array(row.calendars))
+			if err == nil {
+				_, err = tx.ExecContext(ctx, `INSERT INTO coach_calendars (coach_id, calendar_id)
+SELECT $1, unnest($2::text[]) ON CONFLICT DO NOTHING`, coach.ID, pq.Array(row.calendars))
+			}
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+					fmt.Sprintf("Import failed at line %d", row.line), err)
+				return
+			}
+		}
+		written = append(written, coach)
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	for i, coach := range written {
+		action := audit.ActionCoachUpdated
+		if !valid[i].existing {
+			action = audit.ActionCoachCreated
+			if coach.Active {
+				h.syncInBackground(coach.ID)
+			}
+		}
+		h.Deps.Audit.Record(ctx, audit.Entry{
+			Action:     action,
+			EntityType: "coach",
+			EntityID:   coach.ID,
+			After:      coach,
+		})
+	}
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionCoachImported,
+		EntityType: "coach",
+		After:      map[string]int{"created": resp.Created, "updated": resp.Updated},
+	})
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// ExportCoaches handles GET /api/admin/coaches/export. The CSV uses the
+// import columns, so an edited export can be imported back. Inactive coaches
+// are included only with `include_inactive=true`.
+func (h *CoachHandler) ExportCoaches(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	rows, err := h.Deps.DB.QueryContext(ctx, `
+SELECT `+coachColumns+`,
+  ARRAY(SELECT cc.calendar_id FROM coach_calendars cc WHERE cc.coach_id = coaches.id ORDER BY cc.calendar_id)
+FROM coaches
+WHERE tenant_id = $1 AND (active OR $2)
+ORDER BY email`, auth.TenantFrom(ctx), r.URL.Query().Get("include_inactive") == "true")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="coaches.csv"`)
+
+	out := csv.NewWriter(w)
+	out.Write(coachCSVColumns)
+	for rows.Next() {
+		var c structs.CoachProfile
+		var calendars pq.StringArray
+		var updatedAt any
+		err := rows.Scan(&c.ID, &c.Name, &c.Email, &c.Score, &c.ScoreLocked, &c.MaxDailyAppointments,
+			&c.WorkingHours.Start, &c.WorkingHours.End, &c.WorkingHours.Timezone, &c.Active, &c.CreatedAt, &updatedAt,
+			&calendars)
+		if err != nil {
+			// The header is already sent; stop rather than emit a partial row.
+			log.Printf("ExportCoaches: scan coach: %v", err)
+			break
+		}
+		out.Write([]string{
+			c.Name,
+			c.Email,
+			strconv.FormatFloat(c.Score, 'f', -1, 64),
+			strconv.Itoa(c.MaxDailyAppointments),
+			c.WorkingHours.Start,
+			c.WorkingHours.End,
+			c.WorkingHours.Timezone,
+			strings.Join(calendars, calendarSeparator),
+		})
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		log.Printf("ExportCoaches: write CSV: %v", err)
+	}
+}
+
+// tenantSet runs a single-column query scoped by tenantID and returns the
+// values as a set.
+func (h *CoachHandler) tenantSet(r *http.Request, query, tenantID string) (map[string]bool, error) {
+	rows, err := h.Deps.DB.QueryContext(r.Context(), query, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	set := map[string]bool{}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		set[s] = true
+	}
+	return set, rows.Err()
+}
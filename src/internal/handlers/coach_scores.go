@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/transistxr/coach-assignment-server/src/internal/audit"
+	"github.com/transistxr/coach-assignment-server/src/internal/auth"
+	"github.com/transistxr/coach-assignment-server/src/internal/db"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// Score history sources other than the recompute job.
+const (
+	scoreSourceManual = "manual"
+	scoreSourceImport = "import"
+)
+
+// recordScoreChange appends a coach_score_history row. previous is nil for a
+// new coach.
+func recordScoreChange(ctx context.Context, q db.Querier, tenantID, coachID string, previous *float64, score float64, source, reason string) error {
+	_, err := q.ExecContext(ctx, `
+INSERT INTO coach_score_history (tenant_id, coach_id, score, previous_score, source, reason)
+VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))`, tenantID, coachID, score, previous, source, reason)
+	return err
+}
+
+// SetScore handles PUT /api/coaches/{id}/score. The change is recorded in the
+// score history; `locked` keeps the recompute job from overwriting it.
+func (h *CoachHandler) SetScore(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+	tenantID := auth.TenantFrom(ctx)
+	coachID := chi.URLParam(r, "id")
+
+	var req structs.ScoreUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", err)
+		return
+	}
+	if req.Score == nil && req.Locked == nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "score or locked is required", nil)
+		return
+	}
+	if req.Score != nil && (*req.Score < 0 || *req.Score > 1) {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "score must be between 0 and 1", nil)
+		return
+	}
+
+	tx, err := h.Deps.DB.BeginTx(ctx, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := scanCoach(tx.QueryRowContext(ctx, `SELECT `+coachColumns+` FROM coaches
+WHERE id = $1 AND tenant_id = $2 FOR UPDATE`, coachID, tenantID))
+	if err != nil {
+		writeCoachError(w, err)
+		return
+	}
+
+	after, err := scanCoach(tx.QueryRowContext(ctx, `
+UPDATE coaches SET score = COALESCE($2, score), score_locked = COALESCE($3, score_locked), updated_at = NOW()
+WHERE id = $1
+RETURNING `+coachColumns, coachID, req.Score, req.Locked))
+	if err != nil {
+		writeCoachError(w, err)
+		return
+	}
+
+	if after.Score != before.Score {
+		if err := recordScoreChange(ctx, tx, tenantID, coachID, &before.Score, after.Score, scoreSourceManual, req.Reason); err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionCoachScoreChanged,
+		EntityType: "coach",
+		EntityID:   coachID,
+		Before:     map[string]any{"score": before.Score, "score_locked": before.ScoreLocked},
+		After:      map[string]any{"score": after.Score, "score_locked": after.ScoreLocked, "reason": req.Reason},
+	})
+
+	writeJSON(w, http.StatusOK, structs.CoachResponse{CoachProfile: *after})
+}
+
+// ScoreHistory handles GET /api/coaches/{id}/score/history, newest first.
+// `since` (RFC 3339) bounds created_at and `limit` caps the page
+// (default 100).
+func (h *CoachHandler) ScoreHistory(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+	tenantID := auth.TenantFrom(ctx)
+	coachID := chi.URLParam(r, "id")
+
+	var since sql.NullTime
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "since must be an RFC 3339 timestamp", err)
+			return
+		}
+		since = sql.NullTime{Time: t, Valid: true}
+	}
+
+	coach, err := scanCoach(h.Deps.DB.QueryRowContext(ctx, `SELECT `+coachColumns+` FROM coaches
+WHERE id = $1 AND tenant_id = $2`, coachID, tenantID))
+	if err != nil {
+		writeCoachError(w, err)
+		return
+	}
+
+	rows, err := h.Deps.DB.QueryContext(ctx, `
+SELECT id, score, previous_score, source, COALESCE(reason, ''), details, created_at
+FROM coach_score_history
+WHERE coach_id = $1 AND tenant_id = $2 AND ($3::timestamptz IS NULL OR created_at >= $3)
+ORDER BY created_at DESC
+LIMIT $4`, coachID, tenantID, since, parseLimit(r, 100, 1000))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer rows.Close()
+
+	resp := structs.ScoreHistoryResponse{
+		CoachID:     coach.ID,
+		Score:       coach.Score,
+		ScoreLocked: coach.ScoreLocked,
+		History:     []structs.ScoreHistoryEntry{},
+	}
+	for rows.Next() {
+		var e structs.ScoreHistoryEntry
+		var previous sql.NullFloat64
+		var details []byte
+		if err := rows.Scan(&e.ID, &e.Score, &previous, &e.Source, &e.Reason, &details, &e.CreatedAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+		if previous.Valid {
+			e.PreviousScore = &previous.Float64
+		}
+		e.Details = details
+		resp.History = append(resp.History, e)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
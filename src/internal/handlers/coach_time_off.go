@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/transistxr/coach-assignment-server/src/internal/audit"
+	"github.com/transistxr/coach-assignment-server/src/internal/auth"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// timeOffColumns matches scanTimeOff.
+const timeOffColumns = `id, coach_id, start_time, end_time, all_day, reason, created_at`
+
+func scanTimeOff(row rowScanner) (*structs.TimeOff, error) {
+	var t structs.TimeOff
+	var reason sql.NullString
+	if err := row.Scan(&t.ID, &t.CoachID, &t.StartTime, &t.EndTime, &t.AllDay, &reason, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	t.Reason = reason.String
+	return &t, nil
+}
+
+// timeOffPeriod resolves req to a [start, end) period. Whole days are taken
+// in the coach's timezone so a day off covers the coach's local day.
+func timeOffPeriod(req *structs.TimeOffRequest, timezone string) (start, end time.Time, allDay bool, err error) {
+	if req.StartDate == "" {
+		if req.StartTime == nil || req.EndTime == nil {
+			return start, end, false, invalidEvent("start_time and end_time, or start_date, are required", nil)
+		}
+		return req.StartTime.UTC(), req.EndTime.UTC(), false, validateRange(*req.StartTime, *req.EndTime)
+	}
+	if req.StartTime != nil || req.EndTime != nil {
+		return start, end, false, invalidEvent("give either start_time/end_time or start_date/end_date", nil)
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	endDate := req.EndDate
+	if endDate == "" {
+		endDate = req.StartDate
+	}
+	first, errStart := time.ParseInLocation("2006-01-02", req.StartDate, loc)
+	last, errEnd := time.ParseInLocation("2006-01-02", endDate, loc)
+	if errStart != nil || errEnd != nil || last.Before(first) {
+		return start, end, true, invalidEvent("start_date and end_date must be YYYY-MM-DD with start_date first", errors.Join(errStart, errEnd))
+	}
+	return first.UTC(), last.AddDate(0, 0, 1).UTC(), true, nil
+}
+
+// CreateTimeOff handles POST /api/coaches/{id}/time-off. Overlapping slots
+// are marked unavailable straight away and scheduled appointments in the
+// period are flagged with needs_reassignment for the Reassigner to move.
+func (h *CoachHandler) CreateTimeOff(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+	tenantID := auth.TenantFrom(ctx)
+	coachID := chi.URLParam(r, "id")
+
+	var req structs.TimeOffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", err)
+		return
+	}
+
+	var timezone sql.NullString
+	err := h.Deps.DB.QueryRowContext(ctx, `SELECT timezone FROM coaches WHERE id = $1 AND tenant_id = $2`,
+		coachID, tenantID).Scan(&timezone)
+	if err != nil {
+		writeCoachError(w, err)
+		return
+	}
+
+	start, end, allDay, err := timeOffPeriod(&req, timezone.String)
+	if err != nil {
+		writeCoachError(w, err)
+		return
+	}
+
+	tx, err := h.Deps.DB.BeginTx(ctx, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer tx.Rollback()
+
+	timeOff, err := scanTimeOff(tx.QueryRowContext(ctx, `
+INSERT INTO coach_time_off (id, tenant_id, coach_id, start_time, end_time, all_day, reason)
+VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+RETURNING `+timeOffColumns, uuid.NewString(), tenantID, coachID, start, end, allDay, req.Reason))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	_, err = tx.ExecContext(ctx, `
+UPDATE coach_slots SET available = false, updated_at = NOW()
+WHERE coach_id = $1 AND start_time > $2::timestamptz - interval '15 minutes' AND start_time < $3`,
+		coachID, start, end)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+UPDATE coach_appointments SET needs_reassignment = true, reassignment_reason = 'time_off', updated_at = NOW()
+WHERE coach_id = $1 AND status = 'scheduled' AND start_time < $3 AND end_time > $2 AND start_time > NOW()
+RETURNING id`, coachID, start, end)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	var flagged []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+		flagged = append(flagged, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	if len(flagged) > 0 {
+		log.Printf("CreateTimeOff: coach %s has %d appointments needing reassignment", coachID, len(flagged))
+	}
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionCoachTimeOffAdded,
+		EntityType: "coach",
+		EntityID:   coachID,
+		After:      structs.TimeOffResponse{TimeOff: *timeOff, FlaggedAppointments: flagged},
+	})
+
+	writeJSON(w, http.StatusCreated, structs.TimeOffResponse{TimeOff: *timeOff, FlaggedAppointments: flagged})
+}
+
+// ListTimeOff handles GET /api/coaches/{id}/time-off. Past periods are
+// included only with `include_past=true`.
+func (h *CoachHandler) ListTimeOff(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	rows, err := h.Deps.DB.QueryContext(ctx, `
+SELECT `+timeOffColumns+` FROM coach_time_off
+WHERE coach_id = $1 AND tenant_id = $2 AND (end_time > NOW() OR $3)
+ORDER BY start_time`, chi.URLParam(r, "id"), auth.TenantFrom(ctx), r.URL.Query().Get("include_past") == "true")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer rows.Close()
+
+	resp := structs.TimeOffListResponse{TimeOff: []structs.TimeOff{}}
+	for rows.Next() {
+		timeOff, err := scanTimeOff(rows)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+		resp.TimeOff = append(resp.TimeOff, *timeOff)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// DeleteTimeOff handles DELETE /api/coaches/{id}/time-off/{timeOffId}.
+// Appointments no longer covered by any time off are unflagged, and the
+// coach's slots are re-synced from the Calendar API in the background.
+func (h *CoachHandler) DeleteTimeOff(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+	coachID := chi.URLParam(r, "id")
+
+	tx, err := h.Deps.DB.BeginTx(ctx, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer tx.Rollback()
+
+	timeOff, err := scanTimeOff(tx.QueryRowContext(ctx, `
+DELETE FROM coach_time_off WHERE id = $1 AND coach_id = $2 AND tenant_id = $3
+RETURNING `+timeOffColumns, chi.URLParam(r, "timeOffId"), coachID, auth.TenantFrom(ctx)))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Time off not found", nil)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	_, err = tx.ExecContext(ctx, `
+UPDATE coach_appointments ca SET needs_reassignment = false, reassignment_reason = NULL,
+  reassignment_error = NULL, updated_at = NOW()
+WHERE ca.coach_id = $1 AND ca.needs_reassignment AND ca.reassignment_reason = 'time_off'
+  AND ca.start_time < $3 AND ca.end_time > $2
+  AND NOT EXISTS (
+    SELECT 1 FROM coach_time_off t
+    WHERE t.coach_id = ca.coach_id AND t.start_time < ca.end_time AND t.end_time > ca.start_time
+  )`, coachID, timeOff.StartTime, timeOff.EndTime)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionCoachTimeOffRemoved,
+		EntityType: "coach",
+		EntityID:   coachID,
+		Before:     timeOff,
+	})
+
+	h.syncInBackground(coachID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,417 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/transistxr/coach-assignment-server/src/internal/audit"
+	"github.com/transistxr/coach-assignment-server/src/internal/auth"
+	"github.com/transistxr/coach-assignment-server/src/internal/breaker"
+	"github.com/transistxr/coach-assignment-server/src/internal/db"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// newCoachSyncDays is how far ahead availability is fetched for a new or
+// reactivated coach.
+const newCoachSyncDays = 7
+
+// CoachHandler manages the coaches of the caller's tenant. Coaches are never
+// deleted: deactivation keeps their appointments and distribution history but
+// removes them from availability and new assignments.
+type CoachHandler struct {
+	Deps *HandlerDeps
+}
+
+// parseWorkingHours validates wh and returns its parts as nullable columns;
+// omitted parts are NULL. Start and end must be given together.
+func parseWorkingHours(wh *structs.WorkingHours) (start, end, timezone sql.NullString, err error) {
+	if wh == nil {
+		return
+	}
+	if wh.Timezone != "" {
+		if _, tzErr := time.LoadLocation(wh.Timezone); tzErr != nil {
+			err = invalidEvent("Invalid timezone", tzErr)
+			return
+		}
+		timezone = sql.NullString{String: wh.Timezone, Valid: true}
+	}
+	if wh.Start != "" || wh.End != "" {
+		s, errStart := time.Parse("15:04", wh.Start)
+		e, errEnd := time.Parse("15:04", wh.End)
+		if errStart != nil || errEnd != nil || !e.After(s) {
+			err = invalidEvent("working_hours must be HH:MM with start before end", errors.Join(errStart, errEnd))
+			return
+		}
+		start = sql.NullString{String: wh.Start, Valid: true}
+		end = sql.NullString{String: wh.End, Valid: true}
+	}
+	return
+}
+
+// coachColumns matches scanCoach.
+const coachColumns = `id, name, email, score, score_locked, max_daily_appointments,
+to_char(working_hours_start, 'HH24:MI'), to_char(working_hours_end, 'HH24:MI'), timezone, active, created_at, updated_at`
+
+func scanCoach(row rowScanner) (*structs.CoachProfile, error) {
+	var c structs.CoachProfile
+	var updatedAt sql.NullTime
+	err := row.Scan(&c.ID, &c.Name, &c.Email, &c.Score, &c.ScoreLocked, &c.MaxDailyAppointments,
+		&c.WorkingHours.Start, &c.WorkingHours.End, &c.WorkingHours.Timezone, &c.Active, &c.CreatedAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	c.UpdatedAt = nullTimePtr(updatedAt)
+	return &c, nil
+}
+
+// coachParams validates req and converts it to nullable columns.
+type coachParams struct {
+	name, email          sql.NullString
+	score                sql.NullFloat64
+	maxDaily             sql.NullInt64
+	start, end, timezone sql.NullString
+	active               sql.NullBool
+}
+
+func parseCoachRequest(req *structs.CoachRequest) (*coachParams, error) {
+	var p coachParams
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, invalidEvent("name must not be empty", nil)
+		}
+		p.name = sql.NullString{String: name, Valid: true}
+	}
+	if req.Email != nil {
+		addr, err := mail.ParseAddress(*req.Email)
+		if err != nil || addr.Address != *req.Email {
+			return nil, invalidEvent("email must be a plain email address", err)
+		}
+		p.email = sql.NullString{String: addr.Address, Valid: true}
+	}
+	if req.Score != nil {
+		if *req.Score < 0 || *req.Score > 1 {
+			return nil, invalidEvent("score must be between 0 and 1", nil)
+		}
+		p.score = sql.NullFloat64{Float64: *req.Score, Valid: true}
+	}
+	if req.MaxDailyAppointments != nil {
+		if *req.MaxDailyAppointments <= 0 {
+			return nil, invalidEvent("max_daily_appointments must be positive", nil)
+		}
+		p.maxDaily = sql.NullInt64{Int64: int64(*req.MaxDailyAppointments), Valid: true}
+	}
+	var err error
+	p.start, p.end, p.timezone, err = parseWorkingHours(req.WorkingHours)
+	if err != nil {
+		return nil, err
+	}
+	if req.Active != nil {
+		p.active = sql.NullBool{Bool: *req.Active, Valid: true}
+	}
+	return &p, nil
+}
+
+// writeCoachError reports a *webhookError as is and maps a duplicate email to
+// 409; anything else is a database failure.
+func writeCoachError(w http.ResponseWriter, err error) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		writeError(w, http.StatusConflict, "CONFLICT", "A coach with this email already exists", nil)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Coach not found", nil)
+		return
+	}
+	var whErr *webhookError
+	if !errors.As(err, &whErr) {
+		whErr = databaseFailure(err)
+	}
+	writeError(w, whErr.status, whErr.code, whErr.message, whErr.err)
+}
+
+// flagInactiveCoachAppointments flags the coach's future scheduled
+// appointments for reassignment when the coach is deactivated, and clears
+// those flags again if they are reactivated before the Reassigner ran.
+func flagInactiveCoachAppointments(ctx context.Context, q db.Querier, coachID string, inactive bool) error {
+	_, err := q.ExecContext(ctx, `
+UPDATE coach_appointments SET needs_reassignment = $2,
+  reassignment_reason = CASE WHEN $2 THEN 'coach_inactive' END, reassignment_error = NULL, updated_at = NOW()
+WHERE coach_id = $1 AND status = 'scheduled' AND start_time > NOW()
+  AND (CASE WHEN $2 THEN NOT needs_reassignment ELSE reassignment_reason = 'coach_inactive' END)`,
+		coachID, inactive)
+	return err
+}
+
+// syncInBackground loads a coach's availability in the background so it can be
+// booked without waiting for the next GET /api/availability.
+func (h *CoachHandler) syncInBackground(coachID string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		err := h.Deps.syncCoachAvailability(ctx, coachID, newCoachSyncDays)
+		if errors.Is(err, breaker.ErrOpen) {
+			log.Printf("CoachHandler: calendar API unavailable, coach %s will sync on the next availability request", coachID)
+			return
+		}
+		if err != nil {
+			log.Printf("CoachHandler: availability sync for coach %s failed: %v", coachID, err)
+		}
+	}()
+}
+
+// CreateCoach handles POST /api/coaches. name and email are required; other
+// fields default to the column defaults.
+func (h *CoachHandler) CreateCoach(w http.ResponseWriter, r *http.Request) {
+
+	log.Println("Received POST Request: /api/coaches")
+	ctx := r.Context()
+
+	var req structs.CoachRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", err)
+		return
+	}
+	if req.Name == nil || req.Email == nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name and email are required", nil)
+		return
+	}
+
+	p, err := parseCoachRequest(&req)
+	if err != nil {
+		writeCoachError(w, err)
+		return
+	}
+
+	tx, err := h.Deps.DB.BeginTx(ctx, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer tx.Rollback()
+
+	tenantID := auth.TenantFrom(ctx)
+	coach, err := scanCoach(tx.QueryRowContext(ctx, `
+INSERT INTO coaches (id, tenant_id, name, email, score, max_daily_appointments,
+  working_hours_start, working_hours_end, timezone, active)
+VALUES ($1, $2, $3, $4, COALESCE($5, 0.0), COALESCE($6, 10),
+  COALESCE($7::time, '09:00'), COALESCE($8::time, '17:00'), COALESCE($9, 'America/New_York'), COALESCE($10, true))
+RETURNING `+coachColumns, uuid.NewString(), tenantID, p.name, p.email, p.score, p.maxDaily,
+		p.start, p.end, p.timezone, p.active))
+	if err != nil {
+		writeCoachError(w, err)
+		return
+	}
+
+	if err := recordScoreChange(ctx, tx, tenantID, coach.ID, nil, coach.Score, scoreSourceManual, "initial score"); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionCoachCreated,
+		EntityType: "coach",
+		EntityID:   coach.ID,
+		After:      coach,
+	})
+
+	if coach.Active {
+		h.syncInBackground(coach.ID)
+	}
+
+	writeJSON(w, http.StatusCreated, structs.CoachResponse{CoachProfile: *coach})
+}
+
+// ListCoaches handles GET /api/coaches. Inactive coaches are included only
+// with `include_inactive=true`.
+func (h *CoachHandler) ListCoaches(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	rows, err := h.Deps.DB.QueryContext(ctx, `
+SELECT `+coachColumns+` FROM coaches
+WHERE tenant_id = $1 AND (active OR $2)
+ORDER BY name`, auth.TenantFrom(ctx), r.URL.Query().Get("include_inactive") == "true")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer rows.Close()
+
+	resp := structs.CoachListResponse{Coaches: []structs.CoachProfile{}}
+	for rows.Next() {
+		coach, err := scanCoach(rows)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+		resp.Coaches = append(resp.Coaches, *coach)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GetCoach handles GET /api/coaches/{id}.
+func (h *CoachHandler) GetCoach(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	coach, err := scanCoach(h.Deps.DB.QueryRowContext(ctx, `SELECT `+coachColumns+` FROM coaches
+WHERE id = $1 AND tenant_id = $2`, chi.URLParam(r, "id"), auth.TenantFrom(ctx)))
+	if err != nil {
+		writeCoachError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, structs.CoachResponse{CoachProfile: *coach})
+}
+
+// UpdateCoach handles PATCH /api/coaches/{id}. Setting `active` to true
+// reactivates a coach and re-syncs their availability.
+func (h *CoachHandler) UpdateCoach(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+	coachID := chi.URLParam(r, "id")
+
+	var req structs.CoachRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", err)
+		return
+	}
+
+	p, err := parseCoachRequest(&req)
+	if err != nil {
+		writeCoachError(w, err)
+		return
+	}
+
+	tx, err := h.Deps.DB.BeginTx(ctx, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := scanCoach(tx.QueryRowContext(ctx, `SELECT `+coachColumns+` FROM coaches
+WHERE id = $1 AND tenant_id = $2 FOR UPDATE`, coachID, auth.TenantFrom(ctx)))
+	if err != nil {
+		writeCoachError(w, err)
+		return
+	}
+
+	after, err := scanCoach(tx.QueryRowContext(ctx, `
+UPDATE coaches SET
+  name = COALESCE($2, name),
+  email = COALESCE($3, email),
+  score = COALESCE($4, score),
+  max_daily_appointments = COALESCE($5, max_daily_appointments),
+  working_hours_start = COALESCE($6::time, working_hours_start),
+  working_hours_end = COALESCE($7::time, working_hours_end),
+  timezone = COALESCE($8, timezone),
+  active = COALESCE($9, active),
+  updated_at = NOW()
+WHERE id = $1
+RETURNING `+coachColumns, coachID, p.name, p.email, p.score, p.maxDaily, p.start, p.end, p.timezone, p.active))
+	if err != nil {
+		writeCoachError(w, err)
+		return
+	}
+
+	if after.Score != before.Score {
+		err := recordScoreChange(ctx, tx, auth.TenantFrom(ctx), coachID, &before.Score, after.Score, scoreSourceManual, "")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+	}
+
+	if before.Active != after.Active {
+		if err := flagInactiveCoachAppointments(ctx, tx, coachID, !after.Active); err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	action := audit.ActionCoachUpdated
+	if before.Active && !after.Active {
+		action = audit.ActionCoachDeactivated
+	}
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     action,
+		EntityType: "coach",
+		EntityID:   coachID,
+		Before:     before,
+		After:      after,
+	})
+
+	if !before.Active && after.Active {
+		h.syncInBackground(coachID)
+	}
+
+	writeJSON(w, http.StatusOK, structs.CoachResponse{CoachProfile: *after})
+}
+
+// DeactivateCoach handles DELETE /api/coaches/{id}. The coach's future
+// appointments are flagged for the Reassigner to move to other coaches.
+func (h *CoachHandler) DeactivateCoach(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+	coachID := chi.URLParam(r, "id")
+
+	tx, err := h.Deps.DB.BeginTx(ctx, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer tx.Rollback()
+
+	coach, err := scanCoach(tx.QueryRowContext(ctx, `
+UPDATE coaches SET active = false, updated_at = NOW()
+WHERE id = $1 AND tenant_id = $2
+RETURNING `+coachColumns, coachID, auth.TenantFrom(ctx)))
+	if err != nil {
+		writeCoachError(w, err)
+		return
+	}
+
+	if err := flagInactiveCoachAppointments(ctx, tx, coachID, true); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionCoachDeactivated,
+		EntityType: "coach",
+		EntityID:   coachID,
+		After:      coach,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/transistxr/coach-assignment-server/src/internal/audit"
+	"github.com/transistxr/coach-assignment-server/src/internal/auth"
+	"github.com/transistxr/coach-assignment-server/src/internal/signing"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+const maxFeedbackComment = 2000
+
+// FeedbackHandler issues signed feedback links for completed appointments
+// and takes the customer's rating through them. A link works until it
+// expires or feedback for its appointment has been submitted.
+type FeedbackHandler struct {
+	Deps    *HandlerDeps
+	Links   *signing.LinkSigner
+	BaseURL string
+}
+
+// NewFeedbackHandler reads FEEDBACK_LINK_SECRET, FEEDBACK_LINK_TTL_HOURS and
+// FEEDBACK_BASE_URL, the public address links point at. Without a secret no
+// links can be issued or used.
+func NewFeedbackHandler(deps *HandlerDeps) *FeedbackHandler {
+	ttl := 7 * 24 * time.Hour
+	if v, err := strconv.Atoi(os.Getenv("FEEDBACK_LINK_TTL_HOURS")); err == nil && v > 0 {
+		ttl = time.Duration(v) * time.Hour
+	}
+	baseURL := os.Getenv("FEEDBACK_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:3000"
+	}
+	return &FeedbackHandler{
+		Deps:    deps,
+		Links:   signing.NewLinkSigner(os.Getenv("FEEDBACK_LINK_SECRET"), ttl),
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func writeLinkError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, signing.ErrNoLinkSecret):
+		writeError(w, http.StatusServiceUnavailable, "FEEDBACK_DISABLED", "Feedback links are not configured", nil)
+	case errors.Is(err, signing.ErrExpiredToken):
+		writeError(w, http.StatusGone, "LINK_EXPIRED", "This feedback link has expired", nil)
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Feedback link not found", nil)
+	}
+}
+
+// CreateLink handles POST /api/appointments/{id}/feedback-link. The link
+// can be sent to the customer once the appointment is completed; issuing a
+// new one does not revoke earlier links.
+func (h *FeedbackHandler) CreateLink(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+	appointmentID := chi.URLParam(r, "id")
+
+	if err := uuid.Validate(appointmentID); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid appointment id", err)
+		return
+	}
+
+	var status string
+	var submitted bool
+	err := h.Deps.DB.QueryRowContext(ctx, `
+SELECT ca.status, EXISTS (SELECT 1 FROM appointment_feedback f WHERE f.appointment_id = ca.id)
+FROM coach_appointments ca WHERE ca.id = $1 AND ca.tenant_id = $2`, appointmentID, auth.TenantFrom(ctx)).Scan(&status, &submitted)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Appointment not found", nil)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	if status != "completed" {
+		writeError(w, http.StatusConflict, "INVALID_STATE", "Feedback can only be given on completed appointments", nil)
+		return
+	}
+	if submitted {
+		writeError(w, http.StatusConflict, "ALREADY_SUBMITTED", "Feedback has already been submitted", nil)
+		return
+	}
+
+	token, expiresAt, err := h.Links.Sign(appointmentID)
+	if err != nil {
+		writeLinkError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, structs.FeedbackLinkResponse{
+		AppointmentID: appointmentID,
+		URL:           h.BaseURL + "/api/feedback/" + token,
+		ExpiresAt:     expiresAt.UTC(),
+	})
+}
+
+// GetForm handles GET /api/feedback/{token}: what the customer is asked to
+// rate. It needs no API key; the token is the credential.
+func (h *FeedbackHandler) GetForm(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	appointmentID, err := h.Links.Verify(chi.URLParam(r, "token"))
+	if err != nil {
+		writeLinkError(w, err)
+		return
+	}
+
+	form := structs.FeedbackForm{AppointmentID: appointmentID}
+	err = h.Deps.DB.QueryRowContext(ctx, `
+SELECT c.name, cal.name, ca.start_time, ca.end_time,
+  EXISTS (SELECT 1 FROM appointment_feedback f WHERE f.appointment_id = ca.id)
+FROM coach_appointments ca
+JOIN coaches c ON c.id = ca.coach_id
+JOIN calendars cal ON cal.id = ca.calendar_id
+WHERE ca.id = $1`, appointmentID).Scan(&form.CoachName, &form.CalendarName, &form.StartTime, &form.EndTime, &form.Submitted)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeLinkError(w, signing.ErrInvalidToken)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, form)
+}
+
+// Submit handles POST /api/feedback/{token}. Each appointment takes one
+// submission; the link is spent afterwards.
+func (h *FeedbackHandler) Submit(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	appointmentID, err := h.Links.Verify(chi.URLParam(r, "token"))
+	if err != nil {
+		writeLinkError(w, err)
+		return
+	}
+
+	var req structs.FeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", err)
+		return
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if req.Rating < 1 || req.Rating > 5 {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "rating must be between 1 and 5", nil)
+		return
+	}
+	if utf8.RuneCountInString(req.Comment) > maxFeedbackComment {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "comment must be at most 2000 characters", nil)
+		return
+	}
+
+	var tenantID, coachID, status string
+	err = h.Deps.DB.QueryRowContext(ctx, `SELECT tenant_id, coach_id, status FROM coach_appointments WHERE id = $1`,
+		appointmentID).Scan(&tenantID, &coachID, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeLinkError(w, signing.ErrInvalidToken)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	if status != "completed" {
+		writeError(w, http.StatusConflict, "INVALID_STATE", "Feedback can only be given on completed appointments", nil)
+		return
+	}
+
+	var feedback structs.Feedback
+	var comment sql.NullString
+	err = h.Deps.DB.QueryRowContext(ctx, `
+INSERT INTO appointment_feedback (tenant_id, appointment_id, coach_id, rating, comment)
+VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+ON CONFLICT (appointment_id) DO NOTHING
+RETURNING id, appointment_id, coach_id, rating, comment, created_at`,
+		tenantID, appointmentID, coachID, req.Rating, req.Comment).Scan(
+		&feedback.ID, &feedback.AppointmentID, &feedback.CoachID, &feedback.Rating, &comment, &feedback.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusConflict, "ALREADY_SUBMITTED", "Feedback has already been submitted", nil)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	feedback.Comment = comment.String
+
+	log.Printf("Feedback: appointment %s rated %d", appointmentID, feedback.Rating)
+
+	ctx = audit.WithActor(ctx, audit.Actor{Type: audit.ActorCustomer, ID: appointmentID})
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		TenantID:   tenantID,
+		Action:     audit.ActionFeedbackSubmitted,
+		EntityType: "appointment",
+		EntityID:   appointmentID,
+		After:      feedback,
+	})
+
+	writeJSON(w, http.StatusCreated, feedback)
+}
+
+// CoachFeedback handles GET /api/coaches/{id}/feedback: the coach's rating
+// count, average and per-star counts over all feedback, plus the latest
+// entries (`limit`, default 20).
+func (h *FeedbackHandler) CoachFeedback(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+	tenantID := auth.TenantFrom(ctx)
+	coachID := chi.URLParam(r, "id")
+
+	var exists bool
+	err := h.Deps.DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM coaches WHERE id = $1 AND tenant_id = $2)`,
+		coachID, tenantID).Scan(&exists)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Coach not found", nil)
+		return
+	}
+
+	resp := structs.CoachFeedbackResponse{
+		CoachID:  coachID,
+		Ratings:  map[string]int{"1": 0, "2": 0, "3": 0, "4": 0, "5": 0},
+		Feedback: []structs.Feedback{},
+	}
+
+	rows, err := h.Deps.DB.QueryContext(ctx, `
+SELECT rating, COUNT(*) FROM appointment_feedback
+WHERE coach_id = $1 AND tenant_id = $2
+GROUP BY rating`, coachID, tenantID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	sum := 0
+	for rows.Next() {
+		var rating, count int
+		if err := rows.Scan(&rating, &count); err != nil {
+			rows.Close()
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+		resp.Ratings[strconv.Itoa(rating)] = count
+		resp.RatingCount += count
+		sum += rating * count
+	}
+	rows.Close()
+	if resp.RatingCount > 0 {
+		avg := float64(sum) / float64(resp.RatingCount)
+		resp.AvgRating = &avg
+	}
+
+	rows, err = h.Deps.DB.QueryContext(ctx, `
+SELECT id, appointment_id, coach_id, rating, COALESCE(comment, ''), created_at
+FROM appointment_feedback
+WHERE coach_id = $1 AND tenant_id = $2
+ORDER BY created_at DESC
+LIMIT $3`, coachID, tenantID, parseLimit(r, 20, 200))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f structs.Feedback
+		if err := rows.Scan(&f.ID, &f.AppointmentID, &f.CoachID, &f.Rating, &f.Comment, &f.CreatedAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+		resp.Feedback = append(resp.Feedback, f)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
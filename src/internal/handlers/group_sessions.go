@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// openGroupSessions lists the tenant's booked group sessions starting in
+// [from, to) that still have seats, for GetAvailability.
+func (h *SchedulingHandler) openGroupSessions(ctx context.Context, tenantID string, from, to time.Time) ([]structs.GroupSession, error) {
+	rows, err := h.Deps.DB.QueryContext(ctx, `
+SELECT a.coach_id, a.calendar_id, a.start_time, a.end_time, cal.capacity, COUNT(*)
+FROM coach_appointments a
+JOIN calendars cal ON cal.id = a.calendar_id
+JOIN coaches c ON c.id = a.coach_id
+WHERE a.tenant_id = $1 AND a.start_time >= $2 AND a.start_time < $3
+  AND a.status = 'scheduled' AND NOT a.needs_reassignment
+  AND cal.calendar_type = $4 AND cal.active AND c.active
+GROUP BY a.coach_id, a.calendar_id, a.start_time, a.end_time, cal.capacity
+HAVING COUNT(*) < cal.capacity
+ORDER BY a.start_time, a.coach_id`, tenantID, from, to, groupCalendarType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []structs.GroupSession
+	for rows.Next() {
+		var s structs.GroupSession
+		if err := rows.Scan(&s.CoachID, &s.CalendarID, &s.StartTime, &s.EndTime, &s.Capacity, &s.SeatsTaken); err != nil {
+			return nil, err
+		}
+		s.StartTime = s.StartTime.UTC()
+		s.EndTime = s.EndTime.UTC()
+		s.SeatsRemaining = s.Capacity - s.SeatsTaken
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
@@ -1,10 +1,35 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/breaker"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
 )
 
-func HealthCheck(w http.ResponseWriter, r *http.Request) {
+// HealthHandler reports service health together with the circuit breaker
+// state of each downstream. An open breaker marks the service "degraded" but
+// still answers 200, since requests are served from cache or queued.
+type HealthHandler struct {
+	Breakers []*breaker.Breaker
+}
+
+func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
+	resp := structs.HealthResponse{
+		Status:   "ok",
+		Breakers: make(map[string]string, len(h.Breakers)),
+	}
+
+	for _, b := range h.Breakers {
+		state := b.State()
+		resp.Breakers[b.Name()] = string(state)
+		if state != breaker.Closed {
+			resp.Status = "degraded"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+	json.NewEncoder(w).Encode(resp)
 }
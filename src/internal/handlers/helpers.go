@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string, err error) {
+	resp := structs.BaseResponse{Error: code, Message: message}
+	if err != nil {
+		resp.ErrorDetails = err.Error()
+	}
+	writeJSON(w, status, resp)
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	v := t.Time.UTC()
+	return &v
+}
+
+// parseLimit reads the `limit` query parameter, falling back to def and
+// capping at max.
+func parseLimit(r *http.Request, def, max int) int {
+	limit := def
+	if ls := r.URL.Query().Get("limit"); ls != "" {
+		if l, err := strconv.Atoi(ls); err == nil && l > 0 {
+			limit = l
+		}
+	}
+	if limit > max {
+		limit = max
+	}
+	return limit
+}
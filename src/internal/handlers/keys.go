@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/transistxr/coach-assignment-server/src/internal/audit"
+	"github.com/transistxr/coach-assignment-server/src/internal/auth"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+const (
+	apiKeyPrefix    = "ck_"
+	maxRotationDays = 7
+)
+
+var apiKeyTypes = []string{"test", "development", "production"}
+
+// KeyHandler administers the api_keys of the caller's tenant. Plaintext keys
+// are generated here, returned once and only their HMAC is stored. Every
+// change is mirrored to the auth service on a best-effort basis.
+type KeyHandler struct {
+	Deps          *HandlerDeps
+	RotationGrace time.Duration
+}
+
+// NewKeyHandler reads API_KEY_ROTATION_GRACE_SECONDS, the default time an old
+// key keeps working after rotation.
+func NewKeyHandler(deps *HandlerDeps) *KeyHandler {
+	grace := time.Hour
+	if v, err := strconv.Atoi(os.Getenv("API_KEY_ROTATION_GRACE_SECONDS")); err == nil && v >= 0 {
+		grace = time.Duration(v) * time.Second
+	}
+	return &KeyHandler{Deps: deps, RotationGrace: grace}
+}
+
+func newAPIKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + hex.EncodeToString(b), nil
+}
+
+const apiKeyColumns = `id, tenant_id, key_hash, name, key_type, COALESCE(key_prefix, ''), permissions, rate_limit, active,
+expires_at, COALESCE(rotated_from, ''), created_at, last_used_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (*structs.APIKey, string, error) {
+	var k structs.APIKey
+	var keyHash string
+	var permissions []byte
+	var expiresAt, lastUsedAt sql.NullTime
+	err := row.Scan(&k.ID, &k.TenantID, &keyHash, &k.Name, &k.KeyType, &k.KeyPrefix, &permissions, &k.RateLimit, &k.Active,
+		&expiresAt, &k.RotatedFrom, &k.CreatedAt, &lastUsedAt)
+	if err != nil {
+		return nil, "", err
+	}
+	_ = json.Unmarshal(permissions, &k.Permissions)
+	k.ExpiresAt = nullTimePtr(expiresAt)
+	k.LastUsedAt = nullTimePtr(lastUsedAt)
+	return &k, keyHash, nil
+}
+
+// mirrorKey pushes the key's current state to the auth service. Failures are
+// only logged; api_keys stays the source of truth.
+func (h *KeyHandler) mirrorKey(ctx context.Context, key *structs.APIKey, keyHash string) {
+	if err := h.Deps.AuthClient.SyncKey(ctx, &structs.SyncKeyRequest{APIKey: *key, KeyHash: keyHash}); err != nil {
+		log.Printf("KeyHandler: failed to mirror key %s to auth service: %v", key.ID, err)
+	}
+}
+
+// CreateKey handles POST /api/keys. The plaintext key is only in this response.
+func (h *KeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+
+	log.Println("Received POST Request: /api/keys")
+	ctx := r.Context()
+
+	var req structs.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", err)
+		return
+	}
+
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name is required", nil)
+		return
+	}
+	if !slices.Contains(apiKeyTypes, req.KeyType) {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "key_type must be one of test, development, production", nil)
+		return
+	}
+	if req.RateLimit < 0 {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "rate_limit must be positive", nil)
+		return
+	}
+	if req.RateLimit == 0 {
+		req.RateLimit = 100
+	}
+	if req.Permissions == nil {
+		req.Permissions = &structs.Permissions{Read: true, Write: true}
+	}
+
+	plaintext, err := newAPIKey()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Unable to generate key", err)
+		return
+	}
+	keyHash := h.Deps.KeyStore.HashKey(plaintext)
+	permissions, _ := json.Marshal(req.Permissions)
+
+	key, _, err := scanAPIKey(h.Deps.DB.QueryRowContext(ctx, `
+INSERT INTO api_keys (id, key_hash, name, key_type, key_prefix, permissions, rate_limit, tenant_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING `+apiKeyColumns, uuid.NewString(), keyHash, req.Name, req.KeyType,
+		plaintext[:len(apiKeyPrefix)+8], permissions, req.RateLimit, auth.TenantFrom(ctx)))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	h.mirrorKey(ctx, key, keyHash)
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionKeyCreated,
+		EntityType: "api_key",
+		EntityID:   key.ID,
+		After:      key,
+	})
+
+	writeJSON(w, http.StatusCreated, structs.APIKeyResponse{APIKey: *key, Key: plaintext})
+}
+
+// ListKeys handles GET /api/keys. Neither keys nor hashes are returned.
+func (h *KeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	rows, err := h.Deps.DB.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE tenant_id = $1 ORDER BY created_at`,
+		auth.TenantFrom(ctx))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer rows.Close()
+
+	resp := structs.APIKeyListResponse{Keys: []structs.APIKey{}}
+	for rows.Next() {
+		key, _, err := scanAPIKey(rows)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+		resp.Keys = append(resp.Keys, *key)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// DeactivateKey handles DELETE /api/keys/{id}. The key stops working
+// immediately on every replica; the row is kept for auditing.
+func (h *KeyHandler) DeactivateKey(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	key, keyHash, err := scanAPIKey(h.Deps.DB.QueryRowContext(ctx, `
+UPDATE api_keys SET active = false WHERE id = $1 AND tenant_id = $2
+RETURNING `+apiKeyColumns, chi.URLParam(r, "id"), auth.TenantFrom(ctx)))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "API key not found", nil)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	h.Deps.KeyStore.InvalidateHash(ctx, keyHash)
+	h.mirrorKey(ctx, key, keyHash)
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionKeyDeactivated,
+		EntityType: "api_key",
+		EntityID:   key.ID,
+		After:      key,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RotateKey handles POST /api/keys/{id}/rotate. A new key with the same
+// settings is issued and the old one keeps working for grace_period_seconds
+// (API_KEY_ROTATION_GRACE_SECONDS by default, at most 7 days).
+func (h *KeyHandler) RotateKey(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+	oldID := chi.URLParam(r, "id")
+
+	log.Printf("Received POST Request: /api/keys/%s/rotate", oldID)
+
+	var req structs.RotateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", err)
+		return
+	}
+
+	grace := h.RotationGrace
+	if req.GracePeriodSeconds != nil {
+		if *req.GracePeriodSeconds < 0 || *req.GracePeriodSeconds > maxRotationDays*24*3600 {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "grace_period_seconds must be between 0 and 604800", nil)
+			return
+		}
+		grace = time.Duration(*req.GracePeriodSeconds) * time.Second
+	}
+
+	plaintext, err := newAPIKey()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Unable to generate key", err)
+		return
+	}
+	newHash := h.Deps.KeyStore.HashKey(plaintext)
+
+	tx, err := h.Deps.DB.BeginTx(ctx, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer tx.Rollback()
+
+	oldKey, oldHash, err := scanAPIKey(tx.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1 AND tenant_id = $2 FOR UPDATE`,
+		oldID, auth.TenantFrom(ctx)))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "API key not found", nil)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	if !oldKey.Active || (oldKey.ExpiresAt != nil && oldKey.ExpiresAt.Before(time.Now())) {
+		writeError(w, http.StatusConflict, "INVALID_STATE", "Only active, unexpired keys can be rotated", nil)
+		return
+	}
+
+	expiresAt := time.Now().Add(grace).UTC()
+	if oldKey.ExpiresAt != nil && oldKey.ExpiresAt.Before(expiresAt) {
+		expiresAt = *oldKey.ExpiresAt
+	}
+
+	before := *oldKey
+	oldKey, _, err = scanAPIKey(tx.QueryRowContext(ctx, `UPDATE api_keys SET expires_at = $2 WHERE id = $1
+RETURNING `+apiKeyColumns, oldID, expiresAt))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	newKey, _, err := scanAPIKey(tx.QueryRowContext(ctx, `
+INSERT INTO api_keys (id, key_hash, name, key_type, key_prefix, permissions, rate_limit, rotated_from, tenant_id)
+SELECT $2, $3, name, key_type, $4, permissions, rate_limit, id, tenant_id FROM api_keys WHERE id = $1
+RETURNING `+apiKeyColumns, oldID, uuid.NewString(), newHash, plaintext[:len(apiKeyPrefix)+8]))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	// Cached validations of the old key don't know about its expiry yet.
+	h.Deps.KeyStore.InvalidateHash(ctx, oldHash)
+	h.mirrorKey(ctx, oldKey, oldHash)
+	h.mirrorKey(ctx, newKey, newHash)
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionKeyRotated,
+		EntityType: "api_key",
+		EntityID:   oldID,
+		Before:     before,
+		After:      oldKey,
+	})
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionKeyCreated,
+		EntityType: "api_key",
+		EntityID:   newKey.ID,
+		After:      newKey,
+	})
+
+	writeJSON(w, http.StatusOK, structs.RotateAPIKeyResponse{
+		Key:             plaintext,
+		NewKey:          *newKey,
+		OldKeyID:        oldID,
+		OldKeyExpiresAt: expiresAt,
+	})
+}
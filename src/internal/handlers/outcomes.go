@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/transistxr/coach-assignment-server/src/internal/audit"
+	"github.com/transistxr/coach-assignment-server/src/internal/auth"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// outcomeEvents maps the statuses an outcome may set to their event type.
+var outcomeEvents = map[string]string{
+	"completed": structs.EventAppointmentCompleted,
+	"no_show":   structs.EventAppointmentNoShow,
+}
+
+// RecordOutcome handles PUT /api/appointments/{id}/outcome. Once an
+// appointment has started it can be marked completed or no_show, including
+// correcting an outcome set earlier by hand or by the OutcomeRecorder.
+func (h *SchedulingHandler) RecordOutcome(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+	appointmentID := chi.URLParam(r, "id")
+
+	if err := uuid.Validate(appointmentID); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid appointment id", err)
+		return
+	}
+
+	var req structs.OutcomeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", err)
+		return
+	}
+	eventType, ok := outcomeEvents[req.Status]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "status must be completed or no_show", nil)
+		return
+	}
+
+	appt, err := h.loadAppointment(ctx, h.Deps.DB, appointmentID)
+	if err == nil && appt.TenantID != auth.TenantFrom(ctx) {
+		err = &webhookError{status: http.StatusNotFound, code: "NOT_FOUND", message: "Appointment not found"}
+	}
+	if err != nil {
+		var whErr *webhookError
+		if !errors.As(err, &whErr) {
+			whErr = databaseFailure(err)
+		}
+		writeError(w, whErr.status, whErr.code, whErr.message, whErr.err)
+		return
+	}
+
+	switch {
+	case appt.Status != "scheduled" && appt.Status != "completed" && appt.Status != "no_show":
+		writeError(w, http.StatusConflict, "INVALID_STATE", "Appointment is "+appt.Status, nil)
+		return
+	case appt.StartTime.After(time.Now()):
+		writeError(w, http.StatusConflict, "INVALID_STATE", "Appointment has not started yet", nil)
+		return
+	}
+
+	resp := structs.OutcomeResponse{AppointmentID: appointmentID, Status: req.Status}
+
+	// Matching on the loaded status keeps a concurrent change from being
+	// overwritten; setting the same outcome again changes nothing.
+	var recordedAt sql.NullTime
+	err = h.Deps.DB.QueryRowContext(ctx, `
+UPDATE coach_appointments SET status = $2, outcome_source = 'manual', updated_at = NOW(),
+  outcome_recorded_at = CASE WHEN status = $2 THEN outcome_recorded_at ELSE NOW() END
+WHERE id = $1 AND status = $3
+RETURNING outcome_recorded_at`, appointmentID, req.Status, appt.Status).Scan(&recordedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusConflict, "INVALID_STATE", "Appointment changed, retry", nil)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	resp.OutcomeRecordedAt = nullTimePtr(recordedAt)
+
+	if appt.Status != req.Status {
+		h.Deps.Audit.Record(ctx, audit.Entry{
+			TenantID:   appt.TenantID,
+			Action:     eventType,
+			EntityType: "appointment",
+			EntityID:   appointmentID,
+			Before:     appointmentSnapshot(appointmentID, appt, appt.Status),
+			After:      appointmentSnapshot(appointmentID, appt, req.Status),
+		})
+		h.publishAppointment(ctx, eventType, appointmentID, appt, req.Status)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func newOutcomeStats(completed, noShow, ratings int, avgRating sql.NullFloat64) structs.OutcomeStats {
+	stats := structs.OutcomeStats{CompletedCount: completed, NoShowCount: noShow, RatingCount: ratings}
+	if total := completed + noShow; total > 0 {
+		stats.CompletionRate = float64(completed) / float64(total)
+		stats.NoShowRate = float64(noShow) / float64(total)
+	}
+	if avgRating.Valid {
+		stats.AvgRating = &avgRating.Float64
+	}
+	return stats
+}
+
+// outcomeWindowDays reads the `days` query parameter of the distribution
+// report: how far back outcomes are counted.
+func outcomeWindowDays(r *http.Request) int {
+	days := 30
+	if v, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && v > 0 {
+		days = v
+	}
+	if days > 365 {
+		days = 365
+	}
+	return days
+}
+
+// outcomeStats returns the outcome counts and customer ratings of the
+// tenant's appointments that started in the last days days, per coach and per
+// active calendar.
+func (h *SchedulingHandler) outcomeStats(ctx context.Context, tenantID string, days int) (map[string]structs.OutcomeStats, []structs.CalendarDistribution, error) {
+	rows, err := h.Deps.DB.QueryContext(ctx, `
+SELECT ca.coach_id, COUNT(*) FILTER (WHERE ca.status = 'completed'), COUNT(*) FILTER (WHERE ca.status = 'no_show'),
+  COUNT(f.rating), AVG(f.rating)
+FROM coach_appointments ca
+LEFT JOIN appointment_feedback f ON f.appointment_id = ca.id
+WHERE ca.tenant_id = $1 AND ca.start_time >= NOW() - make_interval(days => $2) AND ca.start_time < NOW()
+GROUP BY ca.coach_id`, tenantID, days)
+	if err != nil {
+		return nil, nil, err
+	}
+	byCoach := map[string]structs.OutcomeStats{}
+	for rows.Next() {
+		var coachID string
+		var completed, noShow, ratings int
+		var avgRating sql.NullFloat64
+		if err := rows.Scan(&coachID, &completed, &noShow, &ratings, &avgRating); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		byCoach[coachID] = newOutcomeStats(completed, noShow, ratings, avgRating)
+	}
+	rows.Close()
+
+	rows, err = h.Deps.DB.QueryContext(ctx, `
+SELECT c.id, c.name,
+  COUNT(ca.id) FILTER (WHERE ca.status = 'completed'), COUNT(ca.id) FILTER (WHERE ca.status = 'no_show'),
+  COUNT(f.rating), AVG(f.rating)
+FROM calendars c
+LEFT JOIN coach_appointments ca ON ca.calendar_id = c.id
+  AND ca.start_time >= NOW() - make_interval(days => $2) AND ca.start_time < NOW()
+LEFT JOIN appointment_feedback f ON f.appointment_id = ca.id
+WHERE c.tenant_id = $1 AND c.active
+GROUP BY c.id, c.name
+ORDER BY c.name`, tenantID, days)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	calendars := []structs.CalendarDistribution{}
+	for rows.Next() {
+		var c structs.CalendarDistribution
+		var completed, noShow, ratings int
+		var avgRating sql.NullFloat64
+		if err := rows.Scan(&c.CalendarID, &c.Name, &completed, &noShow, &ratings, &avgRating); err != nil {
+			return nil, nil, err
+		}
+		c.OutcomeStats = newOutcomeStats(completed, noShow, ratings, avgRating)
+		calendars = append(calendars, c)
+	}
+	return byCoach, calendars, rows.Err()
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/auth"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// ReassignmentHandler reports and triggers the reassignment of appointments
+// whose coach became unavailable.
+type ReassignmentHandler struct {
+	Deps *HandlerDeps
+}
+
+// ListPending handles GET /api/reassignments: future appointments still
+// flagged for reassignment, with the last attempt's error if it could not
+// place them.
+func (h *ReassignmentHandler) ListPending(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	rows, err := h.Deps.DB.QueryContext(ctx, `
+SELECT id, coach_id, calendar_id, start_time, end_time, COALESCE(reassignment_reason, ''),
+  COALESCE(reassignment_error, ''), reassignment_attempted_at
+FROM coach_appointments
+WHERE tenant_id = $1 AND needs_reassignment AND status = 'scheduled' AND start_time > NOW()
+ORDER BY start_time
+LIMIT $2`, auth.TenantFrom(ctx), parseLimit(r, 100, 1000))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer rows.Close()
+
+	resp := structs.PendingReassignmentsResponse{Appointments: []structs.PendingReassignment{}}
+	for rows.Next() {
+		var p structs.PendingReassignment
+		var attemptedAt sql.NullTime
+		if err := rows.Scan(&p.AppointmentID, &p.CoachID, &p.CalendarID, &p.StartTime, &p.EndTime,
+			&p.Reason, &p.Error, &attemptedAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+		p.AttemptedAt = nullTimePtr(attemptedAt)
+		resp.Appointments = append(resp.Appointments, p)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Run handles POST /api/reassignments/run. It reassigns the tenant's flagged
+// appointments now instead of waiting for the background job and reports
+// which ones moved and which could not be placed.
+func (h *ReassignmentHandler) Run(w http.ResponseWriter, r *http.Request) {
+
+	report, err := h.Deps.Reassigner.ReassignDue(r.Context(), auth.TenantFrom(r.Context()))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Reassignment failed", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/transistxr/coach-assignment-server/src/internal/auth"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// ListReminders handles GET /api/appointments/{id}/reminders: every reminder
+// scheduled for the appointment, including sent and cancelled ones.
+func (h *SchedulingHandler) ListReminders(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+	appointmentID := chi.URLParam(r, "id")
+
+	if err := uuid.Validate(appointmentID); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid appointment id", err)
+		return
+	}
+
+	var exists bool
+	err := h.Deps.DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM coach_appointments WHERE id = $1 AND tenant_id = $2)`,
+		appointmentID, auth.TenantFrom(ctx)).Scan(&exists)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Appointment not found", nil)
+		return
+	}
+
+	rows, err := h.Deps.DB.QueryContext(ctx, `
+SELECT id, offset_minutes, send_at, status, attempts, COALESCE(last_error, ''), sent_at
+FROM appointment_reminders
+WHERE appointment_id = $1
+ORDER BY send_at`, appointmentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer rows.Close()
+
+	resp := structs.ReminderListResponse{AppointmentID: appointmentID, Reminders: []structs.Reminder{}}
+	for rows.Next() {
+		var rem structs.Reminder
+		var sentAt sql.NullTime
+		if err := rows.Scan(&rem.ID, &rem.OffsetMinutes, &rem.SendAt, &rem.Status, &rem.Attempts,
+			&rem.LastError, &sentAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+		rem.SentAt = nullTimePtr(sentAt)
+		resp.Reminders = append(resp.Reminders, rem)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
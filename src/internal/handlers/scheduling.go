@@ -1,16 +1,30 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"math"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/transistxr/coach-assignment-server/src/internal/assignment"
+	"github.com/transistxr/coach-assignment-server/src/internal/audit"
+	"github.com/transistxr/coach-assignment-server/src/internal/auth"
+	"github.com/transistxr/coach-assignment-server/src/internal/breaker"
 	"github.com/transistxr/coach-assignment-server/src/internal/clients"
 	"github.com/transistxr/coach-assignment-server/src/internal/db"
+	"github.com/transistxr/coach-assignment-server/src/internal/events"
+	"github.com/transistxr/coach-assignment-server/src/internal/jobs"
+	"github.com/transistxr/coach-assignment-server/src/internal/signing"
 	"github.com/transistxr/coach-assignment-server/src/internal/structs"
 
 	"log"
@@ -22,12 +36,41 @@ type HandlerDeps struct {
 	AvailabilityClient *clients.AvailabilityClient
 	CRMClient          *clients.CRMClient
 	AuthClient         *clients.AuthClient
+	KeyStore           *auth.KeyStore
+	Audit              *audit.Logger
+	WebhookVerifier    *signing.Verifier
+	Publisher          *events.Publisher
+	CRMSyncer          *jobs.CRMSyncer
+	CalendarSyncer     *jobs.CalendarSyncer
+	Reassigner         *jobs.Reassigner
+	Reminders          *jobs.ReminderScheduler
 }
 
 type SchedulingHandler struct {
 	Deps *HandlerDeps
+
+	// RecurringEnabled allows booking series; RecurrenceMaxDaysAhead bounds
+	// how far ahead their last occurrence may be.
+	RecurringEnabled       bool
+	RecurrenceMaxDaysAhead int
 }
 
+// NewSchedulingHandler reads ENABLE_RECURRING_APPOINTMENTS and
+// RECURRENCE_MAX_DAYS_AHEAD.
+func NewSchedulingHandler(deps *HandlerDeps) *SchedulingHandler {
+	enabled, _ := strconv.ParseBool(os.Getenv("ENABLE_RECURRING_APPOINTMENTS"))
+	maxDays := 90
+	if v, err := strconv.Atoi(os.Getenv("RECURRENCE_MAX_DAYS_AHEAD")); err == nil && v > 0 {
+		maxDays = v
+	}
+	return &SchedulingHandler{Deps: deps, RecurringEnabled: enabled, RecurrenceMaxDaysAhead: maxDays}
+}
+
+const (
+	calendarWebhookSource = "calendar"
+	maxWebhookBodyBytes   = 1 << 20
+)
+
 func splitInto15MinStarts(start, end time.Time) []time.Time {
 	var slots []time.Time
 	t := start.Truncate(time.Minute).UTC()
@@ -39,48 +82,67 @@ func splitInto15MinStarts(start, end time.Time) []time.Time {
 	return slots
 }
 
+// syncCoachAvailability fetches the coach's next days of availability from
+// the Calendar API and stores it in coach_slots, never freeing a slot held by
+// a scheduled appointment. Only the Calendar API error is returned; failed
+// slot upserts are logged and skipped. Slots overlapping the coach's time off
+// stay unavailable whatever the Calendar API says.
+func (d *HandlerDeps) syncCoachAvailability(ctx context.Context, coachID string, days int) error {
+	avail, err := d.AvailabilityClient.GetAvailability(ctx, coachID, days)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range avail.Slots {
+		start := s.StartTime.UTC()
+		end := s.EndTime.UTC()
+
+		subStarts := splitInto15MinStarts(start, end)
+		for _, st := range subStarts {
+			_, err := d.DB.ExecContext(ctx, `
+INSERT INTO coach_slots (coach_id, start_time, available)
+SELECT $1, $2, $3::boolean AND NOT EXISTS (
+  SELECT 1 FROM coach_time_off t
+  WHERE t.coach_id = $1
+    AND t.start_time < $2::timestamptz + interval '15 minutes'
+    AND t.end_time > $2
+)
+ON CONFLICT (coach_id, start_time) DO UPDATE
+  SET available = CASE
+    WHEN EXISTS (
+      SELECT 1 FROM coach_appointments ca
+      WHERE ca.coach_id = EXCLUDED.coach_id
+        AND ca.start_time = EXCLUDED.start_time
+        AND ca.status = 'scheduled'
+    ) THEN false
+    ELSE EXCLUDED.available
+  END,
+  updated_at = NOW()
+`, coachID, st, s.Available)
+			if err != nil {
+				log.Printf("syncCoachAvailability: upsert slot failed coach=%s start=%s: %v", coachID, st.Format(time.RFC3339), err)
+				continue
+			}
+		}
+	}
+	return nil
+}
+
 // GetAvailability handles GET /api/availability.
 // It calls the external calendar API to fetch availability for each coach,
 // breaks the availability into 15-minute slots, and stores them in the
-// `coach_slots` table. Returns available slots for the requested coach.
+// `coach_slots` table. Returns available slots for the requested coach, and
+// the booked group sessions with seats remaining.
 func (h *SchedulingHandler) GetAvailability(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
+	tenantID := auth.TenantFrom(ctx)
 
 	log.Println("Received GET Request: /api/availability")
 
 	getAvailabilityResponse := &structs.AvailabilityResponse{}
 	w.Header().Set("Content-Type", "application/json")
 
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		getAvailabilityResponse.Error = "VALIDATION_ERROR"
-		getAvailabilityResponse.Message = "X-API-Key Header is missing"
-		json.NewEncoder(w).Encode(getAvailabilityResponse)
-		return
-
-	}
-	authApiValidationResponse, err := h.Deps.AuthClient.ValidateKey(ctx, apiKey)
-
-	log.Println("Auth,", authApiValidationResponse.Valid)
-	if err != nil {
-		w.WriteHeader(http.StatusBadGateway)
-		getAvailabilityResponse.Error = "DOWNSTREAM_ERROR"
-		getAvailabilityResponse.Message = "Downstream service failure"
-		getAvailabilityResponse.ErrorDetails = err.Error()
-		json.NewEncoder(w).Encode(getAvailabilityResponse)
-		return
-	}
-
-	if !authApiValidationResponse.Valid {
-		w.WriteHeader(http.StatusUnauthorized)
-		getAvailabilityResponse.Error = "VALIDATION_ERROR"
-		getAvailabilityResponse.Message = "Invalid API Key"
-		json.NewEncoder(w).Encode(getAvailabilityResponse)
-		return
-	}
-
 	// parse days param
 	days := 7
 	if ds := r.URL.Query().Get("days"); ds != "" {
@@ -93,7 +155,7 @@ func (h *SchedulingHandler) GetAvailability(w http.ResponseWriter, r *http.Reque
 	windowEnd := now.AddDate(0, 0, days)
 
 	// 1) load all coaches from DB
-	rows, err := h.Deps.DB.QueryContext(ctx, `SELECT id FROM coaches`)
+	rows, err := h.Deps.DB.QueryContext(ctx, `SELECT id FROM coaches WHERE tenant_id = $1 AND active`, tenantID)
 	if err != nil {
 		log.Printf("GetAvailability: failed to query coaches: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -120,48 +182,28 @@ func (h *SchedulingHandler) GetAvailability(w http.ResponseWriter, r *http.Reque
 		coachIDs = append(coachIDs, id)
 	}
 
+	// With the Calendar API breaker open, serve the slots already stored in
+	// coach_slots instead of waiting on a downstream known to be failing.
+	degraded := false
 	for _, coachID := range coachIDs {
-		avail, err := h.Deps.AvailabilityClient.GetAvailability(coachID, days)
+		err := h.Deps.syncCoachAvailability(ctx, coachID, days)
+		if errors.Is(err, breaker.ErrOpen) {
+			log.Printf("GetAvailability: calendar API breaker open, serving cached slots")
+			degraded = true
+			break
+		}
 		if err != nil {
 			log.Printf("GetAvailability: calendar API failed for coach %s: %v", coachID, err)
-			continue
-		}
-
-		for _, s := range avail.Slots {
-			start := s.StartTime.UTC()
-			end := s.EndTime.UTC()
-
-			subStarts := splitInto15MinStarts(start, end)
-			for _, st := range subStarts {
-				_, err := h.Deps.DB.ExecContext(ctx, `
-INSERT INTO coach_slots (coach_id, start_time, available)
-VALUES ($1, $2, $3)
-ON CONFLICT (coach_id, start_time) DO UPDATE
-  SET available = CASE
-    WHEN EXISTS (
-      SELECT 1 FROM coach_appointments ca
-      WHERE ca.coach_id = EXCLUDED.coach_id
-        AND ca.start_time = EXCLUDED.start_time
-        AND ca.status = 'scheduled'
-    ) THEN false
-    ELSE EXCLUDED.available
-  END,
-  updated_at = NOW()
-`, coachID, st, s.Available)
-				if err != nil {
-					log.Printf("GetAvailability: upsert slot failed coach=%s start=%s: %v", coachID, st.Format(time.RFC3339), err)
-					continue
-				}
-			}
 		}
 	}
 
 	selRows, err := h.Deps.DB.QueryContext(ctx, `
-SELECT coach_id, start_time
-FROM coach_slots
-WHERE start_time >= $1 AND start_time < $2 AND available = true
-ORDER BY start_time, coach_id
-`, now, windowEnd)
+SELECT s.coach_id, s.start_time
+FROM coach_slots s
+JOIN coaches c ON c.id = s.coach_id
+WHERE s.start_time >= $1 AND s.start_time < $2 AND s.available = true AND c.tenant_id = $3 AND c.active
+ORDER BY s.start_time, s.coach_id
+`, now, windowEnd, tenantID)
 	if err != nil {
 		http.Error(w, "failed to query slots", http.StatusInternalServerError)
 		log.Printf("GetAvailability: select coach_slots: %v", err)
@@ -185,9 +227,16 @@ ORDER BY start_time, coach_id
 		})
 	}
 
+	sessions, err := h.openGroupSessions(ctx, tenantID, now, windowEnd)
+	if err != nil {
+		log.Printf("GetAvailability: select group sessions: %v", err)
+	}
+
 	resp := structs.AvailabilityResponse{
 		Slots:          respSlots,
 		TotalAvailable: len(respSlots),
+		Degraded:       degraded,
+		GroupSessions:  sessions,
 	}
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
@@ -196,51 +245,23 @@ ORDER BY start_time, coach_id
 }
 
 // BookAppointment handles POST /api/appointments.
-// It checks coach eligibility by validating slot duration, appointment conflicts,
-// and daily appointment limits. Then it selects the coach with the highest score,
-// books the appointment in a transaction lock, updates slots, and writes to
-// the distribution log. Also triggers downstream webhooks (CRM, Auth).
+// It picks a coach with assignment.SelectCoach (calendar team, time off, daily
+// limits, free slots, highest score), books the appointment in a transaction
+// lock, updates slots, and writes to the distribution log. Then notifies the CRM and blocks the external calendar;
+// if either is down the booking still succeeds and the call is retried later.
+// With a `recurrence` rule the first occurrence is booked this way and the
+// rest of the series follows; see bookOccurrences.
 func (h *SchedulingHandler) BookAppointment(w http.ResponseWriter, r *http.Request) {
 
 
 	log.Println("Received POST Request: /api/appointments")
 	ctx := r.Context()
-	var selectionReason string
+	tenantID := auth.TenantFrom(ctx)
 
 	var req structs.BookAppointmentRequest
 
 	appointmentBookingResponse := &structs.BookAppointmentResponse{}
 
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		appointmentBookingResponse.Error = "VALIDATION_ERROR"
-		appointmentBookingResponse.Message = "X-API-Key Header is missing"
-		json.NewEncoder(w).Encode(appointmentBookingResponse)
-		return
-
-	}
-	authApiValidationResponse, err := h.Deps.AuthClient.ValidateKey(ctx, apiKey)
-
-	log.Println("Auth,", authApiValidationResponse.Valid)
-	if err != nil {
-		w.WriteHeader(http.StatusBadGateway)
-		appointmentBookingResponse.Error = "DOWNSTREAM_ERROR"
-		appointmentBookingResponse.Message = "Downstream service failure"
-		appointmentBookingResponse.ErrorDetails = err.Error()
-		json.NewEncoder(w).Encode(appointmentBookingResponse)
-		return
-	}
-
-	if !authApiValidationResponse.Valid {
-		w.WriteHeader(http.StatusUnauthorized)
-		appointmentBookingResponse.Error = "VALIDATION_ERROR"
-		appointmentBookingResponse.Message = "Invalid API Key"
-		json.NewEncoder(w).Encode(appointmentBookingResponse)
-		return
-	}
-
-
 	w.Header().Add("Content-Type", "application/json")
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -252,6 +273,25 @@ func (h *SchedulingHandler) BookAppointment(w http.ResponseWriter, r *http.Reque
 
 	log.Println("Request:", req)
 
+	// The timezone is used to word reminders and to step recurring series.
+	timezone := "UTC"
+	if _, err := time.LoadLocation(req.TimeZone); err == nil && req.TimeZone != "" {
+		timezone = req.TimeZone
+	}
+
+	var series *seriesPlan
+	if req.Recurrence != "" {
+		var err error
+		series, err = h.planSeries(req.Recurrence, req.StartTime, timezone)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			appointmentBookingResponse.Error = "VALIDATION_ERROR"
+			appointmentBookingResponse.Message = err.Error()
+			json.NewEncoder(w).Encode(appointmentBookingResponse)
+			return
+		}
+	}
+
 	tx, err := h.Deps.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
 	if err != nil {
 		w.WriteHeader(http.StatusConflict)
@@ -263,10 +303,10 @@ func (h *SchedulingHandler) BookAppointment(w http.ResponseWriter, r *http.Reque
 	defer tx.Rollback()
 
 	var calendarName string
-	var slotDuration int
+	var slotDuration, capacity int
 	err = tx.QueryRowContext(ctx,
-		`SELECT name, slot_duration FROM calendars where id = $1`,
-		req.CalendarID).Scan(&calendarName, &slotDuration)
+		`SELECT name, slot_duration, capacity FROM calendars where id = $1 AND tenant_id = $2 AND active`,
+		req.CalendarID, tenantID).Scan(&calendarName, &slotDuration, &capacity)
 	if err != nil {
 		log.Println("No such calendar")
 		w.WriteHeader(http.StatusBadRequest)
@@ -278,126 +318,46 @@ func (h *SchedulingHandler) BookAppointment(w http.ResponseWriter, r *http.Reque
 
 	log.Printf("For calendar %s, with slot duration %d \n", calendarName, slotDuration)
 
-	query := `
-		SELECT
-			c.id,
-			c.name,
-			c.email,
-			c.score,
-			c.max_daily_appointments,
-			c.working_hours_start,
-			c.working_hours_end,
-			c.timezone
-		FROM coaches c
-		WHERE c.id IN (
-			SELECT coach_id
-			FROM coach_calendars cc
-			WHERE cc.calendar_id = $1
-		)
-	`
-
-	rows, err := tx.QueryContext(ctx, query, req.CalendarID)
-	if err != nil {
-	}
-	defer rows.Close()
-
-	var coaches []structs.Coach
-
-	for rows.Next() {
-		var coach structs.Coach
-		err = rows.Scan(
-			&coach.ID,
-			&coach.Name,
-			&coach.Email,
-			&coach.Score,
-			&coach.MaxDailyAppointments,
-			&coach.WorkingHoursStart,
-			&coach.WorkingHoursEnd,
-			&coach.Timezone,
-		)
-		coaches = append(coaches, coach)
-	}
-
-	log.Println("Coaches with slot and calendar: ", coaches)
-
-	if len(coaches) == 1 {
-		selectionReason = "Only coach with this slot duration"
-	}
-
-	appointmentDay := req.StartTime.Format("2006-01-02")
-	endTime := req.StartTime.Add(time.Duration(slotDuration) * time.Minute)
-
-	query = `SELECT COUNT(*), c.max_daily_appointments
-	FROM coach_appointments ca
-	JOIN coaches c ON ca.coach_id = c.id
-	WHERE ca.coach_id = $1
-	  AND ca.start_time >= ($2::date + c.working_hours_start::interval)
-	  AND ca.end_time <= ($2::date + c.working_hours_end::interval)
-	  AND ca.status = 'scheduled'
-	GROUP BY c.max_daily_appointments;
-	`
-
-	var coachLister []structs.Coach
-
-	for i := 0; i < len(coaches); i++ {
-
-		var currentAppointments, maxDaily int
-		log.Printf("Iter %d, For %s", i, coaches[i].ID)
-		err := tx.QueryRowContext(ctx, query, coaches[i].ID, appointmentDay).Scan(&currentAppointments, &maxDaily)
+	duration := time.Duration(slotDuration) * time.Minute
+	b := &booking{
+		tenantID:     tenantID,
+		calendarID:   req.CalendarID,
+		start:        req.StartTime,
+		end:          req.StartTime.Add(duration),
+		contactID:    "user-" + uuid.New().String(),
+		contactEmail: strings.TrimSpace(req.ContactEmail),
+		contactName:  strings.TrimSpace(req.ContactName),
+		notes:        req.Notes,
+		timezone:     timezone,
+		capacity:     capacity,
+	}
+
+	if series != nil {
+		b.seriesID, err = createSeries(ctx, tx, b, series.rule)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				coachLister = append(coachLister, coaches[i])
-				continue
-			}
-			continue
-		}
-		if currentAppointments < maxDaily {
-			coachLister = append(coachLister, coaches[i])
+			w.WriteHeader(http.StatusInternalServerError)
+			appointmentBookingResponse.Error = "TRANSACTION_ERROR"
+			appointmentBookingResponse.Message = "Unable to create appointment series"
+			appointmentBookingResponse.ErrorDetails = err.Error()
+			json.NewEncoder(w).Encode(appointmentBookingResponse)
+			return
 		}
-
 	}
 
-	log.Println("Coaches with slot and calendar: ", coachLister)
+	appointmentID, top, err := h.placeBooking(ctx, tx, b)
+	if errors.Is(err, errSlotTaken) {
+		w.WriteHeader(http.StatusConflict)
+		appointmentBookingResponse.Error = "NO_SLOT_ERROR"
+		appointmentBookingResponse.Message = "This slot has already been scheduled."
+		appointmentBookingResponse.ErrorDetails = err.Error()
+		json.NewEncoder(w).Encode(appointmentBookingResponse)
 
-	if len(coachLister) == 1 {
-		selectionReason = "Only coach not reaching daily appointment limit"
+		return
 	}
-
-	var newCoachList []structs.Coach
-	for i := 0; i < len(coachLister); i++ {
-		var isAvailable bool
-		err := tx.QueryRowContext(ctx,
-			`
-    SELECT
-        EXISTS (
-            SELECT 1
-            FROM coach_slots s
-            WHERE s.coach_id = $1
-              AND s.start_time = $2
-              AND s.available = TRUE
-        )
-        AND NOT EXISTS (
-            SELECT 1
-            FROM coAch_appointments a
-            WHERE a.coach_id = $1
-              AND a.start_time = $2
-              AND a.status = 'scheduled'
-        ) AS is_available
-`, coachLister[i].ID, req.StartTime).Scan(&isAvailable)
-
-		if err != nil {
-			// test
-		}
-
-		if isAvailable {
-			newCoachList = append(newCoachList, coaches[i])
+	if err != nil {
+		if !errors.Is(err, assignment.ErrNoCoach) {
+			log.Printf("BookAppointment: placing booking failed: %v", err)
 		}
-
-	}
-
-	log.Println("Coaches with slot and calendar: ", newCoachList)
-
-	if len(newCoachList) == 0 {
 		appointmentBookingResponse.Error = "NO_SLOT_ERROR"
 		appointmentBookingResponse.Message = "No slot available at this time for any coach"
 		json.NewEncoder(w).Encode(appointmentBookingResponse)
@@ -405,135 +365,169 @@ func (h *SchedulingHandler) BookAppointment(w http.ResponseWriter, r *http.Reque
 
 	}
 
-	if len(newCoachList) == 1 {
-		selectionReason = "Only coach available at this time"
-	}
+	if err := tx.Commit(); err != nil {
+		w.WriteHeader(http.StatusConflict)
+		appointmentBookingResponse.Error = "TRANSACTION_ERROR"
+		appointmentBookingResponse.Message = "Failed to create appointment"
+		appointmentBookingResponse.ErrorDetails = err.Error()
+		json.NewEncoder(w).Encode(appointmentBookingResponse)
 
-	top := newCoachList[0]
-	for _, c := range newCoachList[1:] {
-		if c.Score > top.Score {
-			top = c
-		}
+		return
 	}
 
-	log.Printf("Best coach %s with score %f", top.Name, top.Score)
-
-	selectionReason = "Selected coach with highest score"
+	h.announceBooking(ctx, appointmentID, top.ID, b)
 
-	appointmentID := uuid.New().String()
+	resp := structs.BookAppointmentResponse{
+		AppointmentID: appointmentID,
+		CoachID:       top.ID,
+		StartTime:     b.start,
+		EndTime:       b.end,
+		Status:        "scheduled",
+	}
+	if series != nil {
+		resp.SeriesID = b.seriesID
+		resp.Occurrences, resp.Unplaced = h.bookOccurrences(ctx, b, top.ID, series.starts[1:], duration)
+		resp.Occurrences = append([]structs.SeriesOccurrence{{
+			Index:         0,
+			AppointmentID: appointmentID,
+			CoachID:       top.ID,
+			StartTime:     b.start,
+			EndTime:       b.end,
+		}}, resp.Occurrences...)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
 
-	userContactID := "user-" + uuid.New().String()
+// errSlotTaken means the appointment row could not be written, normally
+// because the coach already has an appointment starting then.
+var errSlotTaken = errors.New("slot has already been scheduled")
+
+// booking is one appointment to place. Occurrences of a series share the
+// contact and differ in start, end and seriesIndex.
+type booking struct {
+	tenantID     string
+	calendarID   string
+	start        time.Time
+	end          time.Time
+	contactID    string
+	contactEmail string
+	contactName  string
+	notes        string
+	timezone     string
+	// capacity is the calendar's seats per session.
+	capacity int
+
+	// preferredCoachID keeps a series with its first coach when possible.
+	preferredCoachID string
+	seriesID         string
+	seriesIndex      int
+
+	// seat and joined are set by placeBooking from the coach selection.
+	seat   int
+	joined bool
+}
 
-	idemKey := r.Header.Get("X-Idempotency-Key")
-	if idemKey == "" {
-		idemKey = uuid.NewString()
+// placeBooking selects a coach for b and writes the appointment, its slots,
+// reminders and the distribution log in tx. It returns assignment.ErrNoCoach when no
+// coach can take the appointment and errSlotTaken when the insert fails.
+func (h *SchedulingHandler) placeBooking(ctx context.Context, tx *sql.Tx, b *booking) (string, *structs.Coach, error) {
+	selection, err := assignment.SelectCoach(ctx, tx, assignment.Request{
+		TenantID:         b.tenantID,
+		CalendarID:       b.calendarID,
+		StartTime:        b.start,
+		EndTime:          b.end,
+		PreferredCoachID: b.preferredCoachID,
+		Capacity:         b.capacity,
+	})
+	if err != nil {
+		return "", nil, err
 	}
 
-	appointmentCreationRequest := &structs.AppointmentCreatedRequest{
-		AppointmentID: appointmentID,
-		CoachID:       top.ID,
-		StartTime:     req.StartTime,
-		EndTime:       endTime,
-	}
+	top := selection.Coach
+	b.seat = selection.Seat
+	b.joined = selection.Joined
+
+	log.Printf("Best coach %s with score %f", top.Name, top.Score)
+
+	appointmentID := uuid.New().String()
 
+	// The CRM notification and calendar block are queued on the row itself and
+	// sent after commit; the syncers retry them if a downstream is unavailable.
+	// A group session is blocked once, by the seat that opened it, so joining
+	// seats queue no calendar event.
+	calendarEvent := sql.NullString{String: jobs.CalendarEventBlock, Valid: !b.joined}
 	_, err = tx.ExecContext(ctx, `
 		INSERT INTO coach_appointments (
-			id, coach_id, calendar_id, contact_id, title, start_time, end_time, status, source
-		) VALUES ($1,$2,$3,$4,$5, $6, $7, 'scheduled','api')
-	`, appointmentID, top.ID, req.CalendarID, userContactID, req.Notes, req.StartTime, endTime)
+			id, coach_id, calendar_id, contact_id, title, start_time, end_time, status, source,
+			crm_sync_status, crm_sync_event, calendar_sync_status, calendar_sync_event, tenant_id,
+			contact_email, contact_name, timezone, series_id, series_index, seat
+		) VALUES ($1,$2,$3,$4,$5, $6, $7, 'scheduled','api', 'pending', $8,
+			CASE WHEN $9::varchar IS NULL THEN NULL ELSE 'pending' END, $9, $10,
+			NULLIF($11, ''), NULLIF($12, ''), $13, NULLIF($14, ''), $15, $16)
+	`, appointmentID, top.ID, b.calendarID, b.contactID, b.notes, b.start, b.end,
+		jobs.CRMEventCreated, calendarEvent, b.tenantID,
+		b.contactEmail, b.contactName, b.timezone,
+		b.seriesID, sql.NullInt64{Int64: int64(b.seriesIndex), Valid: b.seriesID != ""}, b.seat)
 	if err != nil {
-		w.WriteHeader(http.StatusConflict)
-		appointmentBookingResponse.Error = "NO_SLOT_ERROR"
-		appointmentBookingResponse.Message = "This slot has already been scheduled."
-		appointmentBookingResponse.ErrorDetails = err.Error()
-		json.NewEncoder(w).Encode(appointmentBookingResponse)
-
-		return
+		return "", nil, fmt.Errorf("%w: %v", errSlotTaken, err)
 	}
 
 	_, err = tx.ExecContext(ctx,
 		`UPDATE coach_slots SET available = false, updated_at = NOW() WHERE coach_id = $1
-AND start_time >= $2 AND start_time < $3`, top.ID, req.StartTime, endTime)
+AND start_time >= $2 AND start_time < $3`, top.ID, b.start, b.end)
 
+	considered, _ := json.Marshal(selection.Considered)
 	_, _ = tx.ExecContext(ctx, `
 		INSERT INTO distribution_log (
 			appointment_id, coaches_considered, selected_coach_id, selection_reason, distribution_score
 		) VALUES ($1, $2, $3, $4, $5)
-	`, appointmentID, `[]`, top.ID, selectionReason, 1.0)
-
-	if err := tx.Commit(); err != nil {
-		w.WriteHeader(http.StatusConflict)
-		appointmentBookingResponse.Error = "TRANSACTION_ERROR"
-		appointmentBookingResponse.Message = "Failed to create appointment"
-		appointmentBookingResponse.ErrorDetails = err.Error()
-		json.NewEncoder(w).Encode(appointmentBookingResponse)
-
-		return
-	}
+	`, appointmentID, considered, top.ID, selection.Reason, 1.0)
 
-	response, err := h.Deps.CRMClient.SendAppointmentCreated(ctx, appointmentCreationRequest, idemKey)
-	if err != nil {
-		w.WriteHeader(http.StatusBadGateway)
-		appointmentBookingResponse.Error = "GATEWAY_ERROR"
-		appointmentBookingResponse.Message = "Failed to send appointment creation request to CRM"
-		appointmentBookingResponse.ErrorDetails = err.Error()
-		json.NewEncoder(w).Encode(appointmentBookingResponse)
-
-		return
+	if err := h.Deps.Reminders.Schedule(ctx, tx, appointmentID); err != nil {
+		return "", nil, fmt.Errorf("scheduling reminders: %w", err)
 	}
 
-	_, err = h.Deps.DB.ExecContext(ctx, `
-       UPDATE coach_appointments SET crm_contact_id = $1 WHERE id = $2
-`, response.CrmID, appointmentID)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		appointmentBookingResponse.Error = "INTERNAL_ERROR"
-		appointmentBookingResponse.Message = "Database failure"
-		appointmentBookingResponse.ErrorDetails = err.Error()
-		json.NewEncoder(w).Encode(appointmentBookingResponse)
-
-		return
+	return appointmentID, &top, nil
+}
 
+// announceBooking runs once a booking has committed: audit, subscriber
+// events, the CRM notification and the calendar block.
+func (h *SchedulingHandler) announceBooking(ctx context.Context, appointmentID, coachID string, b *booking) {
+	created := structs.AppointmentEvent{
+		AppointmentID: appointmentID,
+		CoachID:       coachID,
+		CalendarID:    b.calendarID,
+		StartTime:     b.start,
+		EndTime:       b.end,
+		Status:        "scheduled",
 	}
-
-	blockSlotResponse, err := h.Deps.AvailabilityClient.BlockSlot(top.ID, req.StartTime, endTime)
-	if err != nil {
-		w.WriteHeader(http.StatusBadGateway)
-		appointmentBookingResponse.Error = "GATEWAY_ERROR"
-		appointmentBookingResponse.Message = "Failed to send a request to block slot to calendar"
-		appointmentBookingResponse.ErrorDetails = err.Error()
-		json.NewEncoder(w).Encode(appointmentBookingResponse)
-		return
-
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     structs.EventAppointmentCreated,
+		EntityType: "appointment",
+		EntityID:   appointmentID,
+		After:      created,
+	})
+	h.Deps.Publisher.Publish(ctx, b.tenantID, structs.EventAppointmentCreated, created)
+	// Joining a group session adds no time to the coach's day.
+	if !b.joined {
+		h.publishIfCapacityReached(ctx, coachID, b.start.Format("2006-01-02"))
 	}
 
-	_, err = h.Deps.DB.ExecContext(ctx, `
-       UPDATE coach_appointments SET external_calendar_id = $1 WHERE id = $2
-`, blockSlotResponse.BlockId, appointmentID)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		appointmentBookingResponse.Error = "INTERNAL_ERROR"
-		appointmentBookingResponse.Message = "Database failure"
-		appointmentBookingResponse.ErrorDetails = err.Error()
-		json.NewEncoder(w).Encode(appointmentBookingResponse)
-
-		return
+	if err := h.Deps.CRMSyncer.SyncNow(ctx, appointmentID); err != nil {
+		log.Printf("BookAppointment: CRM notification for %s queued for retry: %v", appointmentID, err)
 	}
 
-	resp := structs.BookAppointmentResponse{
-		AppointmentID: appointmentID,
-		CoachID:       top.ID,
-		StartTime:     req.StartTime,
-		EndTime:       endTime,
-		Status:        "scheduled",
+	if err := h.Deps.CalendarSyncer.SyncNow(ctx, appointmentID); err != nil {
+		log.Printf("BookAppointment: calendar block for %s queued for retry: %v", appointmentID, err)
 	}
-	json.NewEncoder(w).Encode(resp)
 }
 
 
 // CalendarWebhook handles POST /api/webhooks/calendar.
 // It receives calendar updates from the external Calendar API (e.g., slot blocked or freed).
-// Updates the `coach_slots` and `coach_appointments`table accordingly to reflect real-time changes.
+// The raw body is authenticated with the calendar source's X-Signature before it is decoded.
+// Each event type is routed to its handler in webhook_events.go, which updates
+// `coach_slots`, `coach_appointments` or coach settings; unknown types are rejected.
 func (h *SchedulingHandler) WebhookHandler(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
@@ -542,34 +536,35 @@ func (h *SchedulingHandler) WebhookHandler(w http.ResponseWriter, r *http.Reques
 
 	webHookResponse := &structs.WebHookResponse{}
 
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		webHookResponse.Error = "VALIDATION_ERROR"
-		webHookResponse.Message = "X-API-Key Header is missing"
-		json.NewEncoder(w).Encode(webHookResponse)
-		return
-
-	}
-	authApiValidationResponse, err := h.Deps.AuthClient.ValidateKey(ctx, apiKey)
-
-	log.Println("Auth,", authApiValidationResponse.Valid)
-	if err != nil {
-		w.WriteHeader(http.StatusBadGateway)
-		webHookResponse.Error = "DOWNSTREAM_ERROR"
-		webHookResponse.Message = "Downstream service failure"
+		webHookResponse.Message = "Unable to read request body"
 		webHookResponse.ErrorDetails = err.Error()
 		json.NewEncoder(w).Encode(webHookResponse)
 		return
 	}
 
-	if !authApiValidationResponse.Valid {
+	// A valid signature seen under a different idempotency key is a replay
+	// of a captured request inside the timestamp window. The signature is
+	// given back unless the event is processed, so the sender can retry.
+	replayed, release, err := verifyAndClaim(h.Deps.WebhookVerifier, h.Deps.RDB, r.Header, body)
+	if err != nil {
+		log.Printf("WebhookHandler: signature verification failed: %v", err)
 		w.WriteHeader(http.StatusUnauthorized)
-		webHookResponse.Error = "VALIDATION_ERROR"
-		webHookResponse.Message = "Invalid API Key"
+		webHookResponse.Error = "SIGNATURE_ERROR"
+		webHookResponse.Message = "Invalid webhook signature"
+		webHookResponse.ErrorDetails = err.Error()
 		json.NewEncoder(w).Encode(webHookResponse)
 		return
 	}
+	processed := false
+	defer func() {
+		if !processed {
+			release()
+		}
+	}()
 
 	idempotencyKey := r.Header.Get("X-Idempotency-Key")
 	if idempotencyKey == "" {
@@ -582,15 +577,24 @@ func (h *SchedulingHandler) WebhookHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	var redisResponse structs.WebHookResponse
-	keyExists, response := db.CheckIdempotency(h.Deps.RDB, idempotencyKey, redisResponse)
+	keyExists, response := db.CheckIdempotency(h.Deps.RDB, idempotencyKey, &redisResponse)
 
 	if keyExists {
 		log.Println("Duplicate request, returning previous response")
 		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if replayed {
+		w.WriteHeader(http.StatusConflict)
+		webHookResponse.Error = "SIGNATURE_ERROR"
+		webHookResponse.Message = "Webhook signature has already been used"
+		json.NewEncoder(w).Encode(webHookResponse)
+		return
 	}
 
 	var req structs.WebHookRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		webHookResponse.Error = "VAIDATION_ERROR"
 		webHookResponse.Message = "Invalid request body"
@@ -600,136 +604,159 @@ func (h *SchedulingHandler) WebhookHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	appointmentQuery := `SELECT coach_id, calendar_id, start_time, end_time, external_calendar_id FROM coach_appointments WHERE id = $1`
-
-	appointmentCancellationQuery := `UPDATE coach_appointments SET status = 'cancelled', updated_at = NOW(), cancelled_at = NOW(),
-webhook_attempts = webhook_attempts + 1, webhook_last_attempt = NOW() WHERE id = $1`
-
-	appointmentConfirmedQuery := `UPDATE coach_appointments SET updated_at = NOW(), confirmed_at = NOW(),
-webhook_attempts = webhook_attempts + 1, webhook_last_attempt = NOW() WHERE id = $1`
-
-	slotFreeingQuery := `UPDATE coach_slots SET available = true, updated_at = NOW() WHERE coach_id = $1
-AND start_time >= $2 AND start_time < $3`
-
-	insertWebhookEventQuery := `INSERT INTO webhook_events
-(id, event_type, event_source, payload, last_attempt, processed_at, created_at)
-VALUES ($1, $2, $3, $4, NOW(), NOW(), NOW())`
-
-	b, err := json.Marshal(req)
-	if err != nil {
-		log.Println("Failed to marshall request")
+	eventHandler, ok := h.webhookEventHandlers()[req.EventType]
+	if !ok {
 		w.WriteHeader(http.StatusBadRequest)
-		webHookResponse.Error = "VAIDATION_ERROR"
-		webHookResponse.Message = "Invalid request body"
-		webHookResponse.ErrorDetails = err.Error()
+		webHookResponse.Error = "UNSUPPORTED_EVENT_TYPE"
+		webHookResponse.Message = "Unknown event type: " + req.EventType
 		json.NewEncoder(w).Encode(webHookResponse)
-
 		return
 	}
 
+	insertWebhookEventQuery := `INSERT INTO webhook_events
+(id, event_type, event_source, payload, status, attempts, last_attempt, created_at)
+VALUES ($1, $2, $3, $4, 'pending', 1, NOW(), NOW())`
+
 	eventId := uuid.New().String()
 
-	_, err = h.Deps.DB.ExecContext(ctx, insertWebhookEventQuery, eventId, req.EventType, "webhook", b)
+	_, err = h.Deps.DB.ExecContext(ctx, insertWebhookEventQuery, eventId, req.EventType, calendarWebhookSource, body)
 	if err != nil {
 		log.Println("Failed to insert webhook entry")
 
 	}
 
-	var coachID string
-	var calendarID string
-	var blockID string
-	var startTime time.Time
-	var endTime time.Time
+	ctx = audit.WithActor(ctx, audit.Actor{Type: audit.ActorWebhook, ID: calendarWebhookSource})
+	if err := eventHandler(ctx, &req); err != nil {
+		log.Printf("WebhookHandler: %s failed: %v", req.EventType, err)
+		_, _ = h.Deps.DB.ExecContext(ctx, `UPDATE webhook_events SET status = 'failed', error_message = $2 WHERE id = $1`, eventId, err.Error())
 
-	err = h.Deps.DB.QueryRowContext(ctx, appointmentQuery, req.AppointmentID).Scan(&coachID, &calendarID, &startTime, &endTime, &blockID)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		webHookResponse.Error = "INTERNAL_ERROR"
-		webHookResponse.Message = "Database failure"
-		webHookResponse.ErrorDetails = err.Error()
+		var whErr *webhookError
+		if !errors.As(err, &whErr) {
+			whErr = databaseFailure(err)
+		}
+		w.WriteHeader(whErr.status)
+		webHookResponse.Error = whErr.code
+		webHookResponse.Message = whErr.message
+		if whErr.err != nil {
+			webHookResponse.ErrorDetails = whErr.err.Error()
+		}
+		webHookResponse.EventID = eventId
 		json.NewEncoder(w).Encode(webHookResponse)
-
 		return
-
 	}
 
-	switch req.EventType {
-	case "appointment.cancelled":
-		_, err = h.Deps.DB.ExecContext(ctx, appointmentCancellationQuery, req.AppointmentID)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			webHookResponse.Error = "INTERNAL_ERROR"
-			webHookResponse.Message = "Database failure"
-			webHookResponse.ErrorDetails = err.Error()
-			json.NewEncoder(w).Encode(webHookResponse)
+	_, _ = h.Deps.DB.ExecContext(ctx, `UPDATE webhook_events SET status = 'processed', processed_at = NOW() WHERE id = $1`, eventId)
+	processed = true
 
-			return
-
-		}
-		_, err = h.Deps.DB.ExecContext(ctx, slotFreeingQuery, coachID, startTime, endTime)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			webHookResponse.Error = "INTERNAL_ERROR"
-			webHookResponse.Message = "Database failure"
-			webHookResponse.ErrorDetails = err.Error()
-			json.NewEncoder(w).Encode(webHookResponse)
+	webHookResponse = &structs.WebHookResponse{
+		Received: true,
+		EventID:  eventId,
+	}
 
-			return
+	_ = db.SetIdempotencyKey(h.Deps.RDB, idempotencyKey, webHookResponse)
 
-		}
+	json.NewEncoder(w).Encode(webHookResponse)
 
-		releaseSlotResponse, err := h.Deps.AvailabilityClient.ReleaseSlot(coachID, blockID, startTime, endTime)
-		if err != nil {
-			w.WriteHeader(http.StatusBadGateway)
-			webHookResponse.Error = "GATEWAY_ERROR"
-			webHookResponse.Message = "Failed to send a request to block slot to calendar"
-			webHookResponse.ErrorDetails = err.Error()
-			json.NewEncoder(w).Encode(webHookResponse)
-			return
-		}
+}
 
-		log.Printf("Response received %s", releaseSlotResponse.BlockId)
 
-	case "appointment.confirmed":
-		_, err = h.Deps.DB.ExecContext(ctx, appointmentConfirmedQuery, req.AppointmentID)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			webHookResponse.Error = "INTERNAL_ERROR"
-			webHookResponse.Message = "Database failure"
-			webHookResponse.ErrorDetails = err.Error()
-			json.NewEncoder(w).Encode(webHookResponse)
+// CancelAppointment handles DELETE /api/appointments/{id}.
+// An optional `reason` query parameter is stored and forwarded to the CRM.
+// With scope=following, the later scheduled occurrences of the appointment's
+// series are cancelled too.
+func (h *SchedulingHandler) CancelAppointment(w http.ResponseWriter, r *http.Request) {
 
-			return
+	ctx := r.Context()
+	appointmentID := chi.URLParam(r, "id")
 
-		}
+	log.Printf("Received DELETE Request: /api/appointments/%s", appointmentID)
 
+	if err := uuid.Validate(appointmentID); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid appointment id", err)
+		return
 	}
 
-
-	webHookResponse = &structs.WebHookResponse{
-		Received: true,
-		EventID:  eventId,
+	scope := r.URL.Query().Get("scope")
+	if scope != "" && scope != "single" && scope != "following" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "scope must be single or following", nil)
+		return
 	}
 
-	_ = db.SetIdempotencyKey(h.Deps.RDB, idempotencyKey, webHookResponse)
+	var cancelled []string
+	err := func() error {
+		appt, err := h.loadAppointment(ctx, h.Deps.DB, appointmentID)
+		if err != nil {
+			return err
+		}
+		if appt.TenantID != auth.TenantFrom(ctx) {
+			return &webhookError{status: http.StatusNotFound, code: "NOT_FOUND", message: "Appointment not found"}
+		}
+		if appt.Status != "scheduled" {
+			return notScheduled("cancelled")
+		}
+		if err := h.cancelAppointment(ctx, appointmentID, appt, r.URL.Query().Get("reason")); err != nil {
+			return err
+		}
+		if scope != "following" {
+			return nil
+		}
 
-	json.NewEncoder(w).Encode(webHookResponse)
+		cancelled = append(cancelled, appointmentID)
+		if !appt.SeriesID.Valid {
+			return nil
+		}
+		following, err := h.followingOccurrences(ctx, appt)
+		if err != nil {
+			return err
+		}
+		for _, id := range following {
+			next, err := h.loadAppointment(ctx, h.Deps.DB, id)
+			if err != nil {
+				return err
+			}
+			if err := h.cancelAppointment(ctx, id, next, r.URL.Query().Get("reason")); err != nil {
+				// An occurrence cancelled or completed meanwhile is skipped.
+				var whErr *webhookError
+				if errors.As(err, &whErr) && whErr.code == "INVALID_STATE" {
+					continue
+				}
+				return err
+			}
+			cancelled = append(cancelled, id)
+		}
+		return nil
+	}()
+	if err != nil {
+		var whErr *webhookError
+		if !errors.As(err, &whErr) {
+			whErr = databaseFailure(err)
+		}
+		writeError(w, whErr.status, whErr.code, whErr.message, whErr.err)
+		return
+	}
 
+	writeJSON(w, http.StatusOK, structs.CancelAppointmentResponse{
+		AppointmentID:         appointmentID,
+		Status:                "cancelled",
+		CancelledAppointments: cancelled,
+	})
 }
 
 
 // GetCoachDistribution handles GET /api/coaches/distribution.
-// It aggregates appointment counts and utilization metrics for each coach,
-// computes the fairness_score across coaches, and returns distribution data.
+// It aggregates appointment counts and utilization metrics for each coach of
+// the caller's tenant, computes the fairness_score across those coaches, and
+// returns distribution data. Completion and no-show rates per coach and per
+// calendar cover the last `days` days (default 30).
 func (h *SchedulingHandler) GetCoachDistribution(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 	query := `
-		SELECT id, name, email, score, max_daily_appointments FROM coaches`
+		SELECT id, name, email, score, max_daily_appointments FROM coaches WHERE tenant_id = $1 AND active`
 
 	appointmentsCount := `SELECT COUNT(*) as appointments_count FROM coach_appointments
 WHERE start_time::date = CURRENT_DATE and coach_id = $1`
-	rows, err := h.Deps.DB.QueryContext(ctx, query)
+	rows, err := h.Deps.DB.QueryContext(ctx, query, auth.TenantFrom(ctx))
 	if err != nil {
 	}
 	defer rows.Close()
@@ -754,6 +781,13 @@ WHERE start_time::date = CURRENT_DATE and coach_id = $1`
 
 	var response structs.CoachDistributionResponse
 
+	days := outcomeWindowDays(r)
+	outcomes, calendars, err := h.outcomeStats(ctx, auth.TenantFrom(ctx), days)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
 	var utilizationList []float64
 	for _, coach := range coaches {
 		var appointments int
@@ -767,12 +801,15 @@ WHERE start_time::date = CURRENT_DATE and coach_id = $1`
 			Score:             coach.Score,
 			AppointmentsCount: appointments,
 			Utilization:       utilization,
+			OutcomeStats:      outcomes[coach.ID],
 		}
 		coachDistributionList = append(coachDistributionList, coachDistribution)
 	}
 
 	response.Distribution = coachDistributionList
+	response.Calendars = calendars
 	response.FairnessScore = ComputeFairnessScore(utilizationList)
+	response.OutcomeWindowDays = days
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/transistxr/coach-assignment-server/src/internal/assignment"
+	"github.com/transistxr/coach-assignment-server/src/internal/recurrence"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// seriesPlan is a validated recurrence and the start of every occurrence,
+// the first being the requested start_time.
+type seriesPlan struct {
+	rule   *recurrence.Rule
+	starts []time.Time
+}
+
+// planSeries validates a booking's recurrence against the feature flag and
+// the booking horizon.
+func (h *SchedulingHandler) planSeries(rule string, start time.Time, timezone string) (*seriesPlan, error) {
+	if !h.RecurringEnabled {
+		return nil, errors.New("Recurring appointments are disabled")
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	parsed, err := recurrence.Parse(rule, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	starts := parsed.Occurrences(start, loc)
+	if len(starts) == 0 {
+		return nil, errors.New("recurrence ends before start_time")
+	}
+	horizon := time.Now().AddDate(0, 0, h.RecurrenceMaxDaysAhead)
+	if last := starts[len(starts)-1]; last.After(horizon) {
+		return nil, fmt.Errorf("the series must end within %d days, its last occurrence is %s",
+			h.RecurrenceMaxDaysAhead, last.Format(time.RFC3339))
+	}
+	return &seriesPlan{rule: parsed, starts: starts}, nil
+}
+
+// createSeries records the series the booking's appointments belong to.
+func createSeries(ctx context.Context, tx *sql.Tx, b *booking, rule *recurrence.Rule) (string, error) {
+	seriesID := uuid.New().String()
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO appointment_series (id, tenant_id, calendar_id, contact_id, rrule, timezone, first_start_time)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`, seriesID, b.tenantID, b.calendarID, b.contactID, rule.String(), b.timezone, b.start)
+	return seriesID, err
+}
+
+// bookOccurrences books the remaining occurrences of a series, each in its
+// own transaction, preferring coachID. Availability of the calendar's team is
+// synced far enough ahead first; occurrences nobody can take are reported
+// rather than failing the series.
+func (h *SchedulingHandler) bookOccurrences(ctx context.Context, first *booking, coachID string, starts []time.Time, duration time.Duration) ([]structs.SeriesOccurrence, []structs.UnplacedOccurrence) {
+	booked := []structs.SeriesOccurrence{}
+	unplaced := []structs.UnplacedOccurrence{}
+	if len(starts) == 0 {
+		return booked, unplaced
+	}
+
+	days := int(math.Ceil(time.Until(starts[len(starts)-1].Add(duration)).Hours()/24)) + 1
+	h.syncTeamAvailability(ctx, first.tenantID, first.calendarID, days)
+
+	for i, start := range starts {
+		b := *first
+		b.start = start
+		b.end = start.Add(duration)
+		b.seriesIndex = i + 1
+		b.preferredCoachID = coachID
+
+		appointmentID, coach, err := h.bookOccurrence(ctx, &b)
+		if err != nil {
+			var reason string
+			switch {
+			case errors.Is(err, assignment.ErrNoCoach):
+				reason = assignment.ErrNoCoach.Error()
+			case errors.Is(err, errSlotTaken):
+				reason = errSlotTaken.Error()
+			default:
+				log.Printf("BookAppointment: series %s occurrence %d failed: %v", b.seriesID, b.seriesIndex, err)
+				reason = "booking failed"
+			}
+			unplaced = append(unplaced, structs.UnplacedOccurrence{
+				Index:     b.seriesIndex,
+				StartTime: b.start,
+				EndTime:   b.end,
+				Reason:    reason,
+			})
+			continue
+		}
+
+		h.announceBooking(ctx, appointmentID, coach.ID, &b)
+		booked = append(booked, structs.SeriesOccurrence{
+			Index:         b.seriesIndex,
+			AppointmentID: appointmentID,
+			CoachID:       coach.ID,
+			StartTime:     b.start,
+			EndTime:       b.end,
+		})
+	}
+	return booked, unplaced
+}
+
+func (h *SchedulingHandler) bookOccurrence(ctx context.Context, b *booking) (string, *structs.Coach, error) {
+	tx, err := h.Deps.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return "", nil, err
+	}
+	defer tx.Rollback()
+
+	appointmentID, coach, err := h.placeBooking(ctx, tx, b)
+	if err != nil {
+		return "", nil, err
+	}
+	return appointmentID, coach, tx.Commit()
+}
+
+// syncTeamAvailability refreshes coach_slots for the calendar's active
+// coaches. Failures are logged: occurrences are then placed from the slots
+// already stored.
+func (h *SchedulingHandler) syncTeamAvailability(ctx context.Context, tenantID, calendarID string, days int) {
+	rows, err := h.Deps.DB.QueryContext(ctx, `
+SELECT c.id FROM coaches c
+JOIN coach_calendars cc ON cc.coach_id = c.id
+WHERE cc.calendar_id = $1 AND c.tenant_id = $2 AND c.active`, calendarID, tenantID)
+	if err != nil {
+		log.Printf("BookAppointment: list calendar team: %v", err)
+		return
+	}
+	var coachIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			coachIDs = append(coachIDs, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range coachIDs {
+		if err := h.Deps.syncCoachAvailability(ctx, id, days); err != nil {
+			log.Printf("BookAppointment: calendar API failed for coach %s, using stored slots: %v", id, err)
+		}
+	}
+}
+
+// followingOccurrences lists the scheduled occurrences after appt in its
+// series, in order.
+func (h *SchedulingHandler) followingOccurrences(ctx context.Context, appt *appointmentRecord) ([]string, error) {
+	rows, err := h.Deps.DB.QueryContext(ctx, `
+SELECT id FROM coach_appointments
+WHERE series_id = $1 AND tenant_id = $2 AND series_index > $3 AND status = 'scheduled'
+ORDER BY series_index`, appt.SeriesID.String, appt.TenantID, appt.SeriesIndex.Int64)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
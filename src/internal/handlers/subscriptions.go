@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/transistxr/coach-assignment-server/src/internal/audit"
+	"github.com/transistxr/coach-assignment-server/src/internal/auth"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// SubscriptionHandler manages outbound webhook subscriptions for internal
+// consumers and exposes their delivery log.
+type SubscriptionHandler struct {
+	Deps *HandlerDeps
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CreateSubscription handles POST /api/subscriptions.
+// The signing secret is generated when not supplied and is only returned here.
+func (h *SubscriptionHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+
+	log.Println("Received POST Request: /api/subscriptions")
+	ctx := r.Context()
+
+	var req structs.CreateSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body", err)
+		return
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "url must be an absolute http(s) URL", err)
+		return
+	}
+
+	if len(req.EventTypes) == 0 {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "event_types must not be empty", nil)
+		return
+	}
+	for _, et := range req.EventTypes {
+		if !slices.Contains(structs.SubscribableEvents, et) {
+			writeError(w, http.StatusBadRequest, "UNSUPPORTED_EVENT_TYPE", "Unknown event type: "+et, nil)
+			return
+		}
+	}
+
+	if req.Secret == "" {
+		req.Secret, err = newSecret()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Unable to generate secret", err)
+			return
+		}
+	}
+
+	eventTypes, _ := json.Marshal(req.EventTypes)
+
+	sub := structs.Subscription{
+		ID:          uuid.NewString(),
+		URL:         req.URL,
+		Secret:      req.Secret,
+		EventTypes:  req.EventTypes,
+		Description: req.Description,
+		Active:      true,
+	}
+
+	err = h.Deps.DB.QueryRowContext(ctx, `
+INSERT INTO webhook_subscriptions (id, url, secret, event_types, description, tenant_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING created_at`, sub.ID, sub.URL, sub.Secret, eventTypes, sub.Description, auth.TenantFrom(ctx)).Scan(&sub.CreatedAt)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+
+	snapshot := sub
+	snapshot.Secret = ""
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionSubscriptionCreated,
+		EntityType: "subscription",
+		EntityID:   sub.ID,
+		After:      snapshot,
+	})
+
+	writeJSON(w, http.StatusCreated, structs.SubscriptionResponse{Subscription: &sub})
+}
+
+// ListSubscriptions handles GET /api/subscriptions. Secrets are not returned.
+func (h *SubscriptionHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	rows, err := h.Deps.DB.QueryContext(ctx, `
+SELECT id, url, event_types, COALESCE(description, ''), active, created_at
+FROM webhook_subscriptions WHERE tenant_id = $1 ORDER BY created_at`, auth.TenantFrom(ctx))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer rows.Close()
+
+	resp := structs.SubscriptionListResponse{Subscriptions: []structs.Subscription{}}
+	for rows.Next() {
+		var sub structs.Subscription
+		var eventTypes []byte
+		if err := rows.Scan(&sub.ID, &sub.URL, &eventTypes, &sub.Description, &sub.Active, &sub.CreatedAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+		_ = json.Unmarshal(eventTypes, &sub.EventTypes)
+		resp.Subscriptions = append(resp.Subscriptions, sub)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// DeleteSubscription handles DELETE /api/subscriptions/{id}. The subscription
+// is deactivated rather than removed so its delivery log stays queryable.
+func (h *SubscriptionHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	res, err := h.Deps.DB.ExecContext(ctx, `UPDATE webhook_subscriptions SET active = false WHERE id = $1 AND tenant_id = $2`,
+		chi.URLParam(r, "id"), auth.TenantFrom(ctx))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Subscription not found", nil)
+		return
+	}
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionSubscriptionDeactivated,
+		EntityType: "subscription",
+		EntityID:   chi.URLParam(r, "id"),
+		After:      map[string]bool{"active": false},
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries handles GET /api/subscriptions/{id}/deliveries, newest first.
+// `status` filters by delivery status and `limit` caps the page (default 50).
+func (h *SubscriptionHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	limit := parseLimit(r, 50, 500)
+	var status sql.NullString
+	if s := r.URL.Query().Get("status"); s != "" {
+		status = sql.NullString{String: s, Valid: true}
+	}
+
+	rows, err := h.Deps.DB.QueryContext(ctx, `
+SELECT id, event_id, event_type, payload, status, attempts, response_status,
+       COALESCE(error_message, ''), last_attempt, delivered_at, created_at
+FROM webhook_deliveries
+WHERE subscription_id = (SELECT id FROM webhook_subscriptions WHERE id = $1 AND tenant_id = $4)
+  AND ($2::varchar IS NULL OR status = $2)
+ORDER BY created_at DESC
+LIMIT $3`, chi.URLParam(r, "id"), status, limit, auth.TenantFrom(ctx))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+		return
+	}
+	defer rows.Close()
+
+	resp := structs.DeliveryListResponse{Deliveries: []structs.WebhookDelivery{}}
+	for rows.Next() {
+		var d structs.WebhookDelivery
+		var responseStatus sql.NullInt64
+		var lastAttempt, deliveredAt sql.NullTime
+		if err := rows.Scan(&d.ID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+			&responseStatus, &d.ErrorMessage, &lastAttempt, &deliveredAt, &d.CreatedAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Database failure", err)
+			return
+		}
+		if responseStatus.Valid {
+			s := int(responseStatus.Int64)
+			d.ResponseStatus = &s
+		}
+		d.LastAttempt = nullTimePtr(lastAttempt)
+		d.DeliveredAt = nullTimePtr(deliveredAt)
+		resp.Deliveries = append(resp.Deliveries, d)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
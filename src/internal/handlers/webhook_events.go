@@ -0,0 +1,621 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/audit"
+	"github.com/transistxr/coach-assignment-server/src/internal/db"
+	"github.com/transistxr/coach-assignment-server/src/internal/jobs"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// webhookError carries the HTTP status and error code a failed calendar event
+// should be reported with.
+type webhookError struct {
+	status  int
+	code    string
+	message string
+	err     error
+}
+
+func (e *webhookError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("%s: %v", e.message, e.err)
+	}
+	return e.message
+}
+
+func (e *webhookError) Unwrap() error {
+	return e.err
+}
+
+func invalidEvent(message string, err error) *webhookError {
+	return &webhookError{status: http.StatusBadRequest, code: "VALIDATION_ERROR", message: message, err: err}
+}
+
+func databaseFailure(err error) *webhookError {
+	return &webhookError{status: http.StatusInternalServerError, code: "INTERNAL_ERROR", message: "Database failure", err: err}
+}
+
+// notScheduled rejects an action on an appointment that is no longer, or was
+// concurrently taken out of, the scheduled state.
+func notScheduled(action string) *webhookError {
+	return &webhookError{status: http.StatusConflict, code: "INVALID_STATE", message: "Only scheduled appointments can be " + action}
+}
+
+type webhookEventHandler func(ctx context.Context, req *structs.WebHookRequest) error
+
+// webhookEventHandlers routes each supported calendar event type to the
+// handler that applies it. Types missing from this map are rejected.
+func (h *SchedulingHandler) webhookEventHandlers() map[string]webhookEventHandler {
+	return map[string]webhookEventHandler{
+		structs.EventAppointmentCancelled:   h.handleAppointmentCancelled,
+		structs.EventAppointmentConfirmed:   h.handleAppointmentConfirmed,
+		structs.EventAppointmentRescheduled: h.handleAppointmentRescheduled,
+		structs.EventSlotBlocked:            h.handleSlotBlocked,
+		structs.EventSlotReleased:           h.handleSlotReleased,
+		structs.EventCoachSettingsChanged:   h.handleCoachSettingsChanged,
+	}
+}
+
+// decodeEventData unmarshals the event payload into out. Appointment events
+// sent in the legacy flat shape only carry a top-level appointment_id.
+func decodeEventData(req *structs.WebHookRequest, out any) error {
+	if len(req.Data) == 0 {
+		if req.AppointmentID == "" {
+			return invalidEvent("Missing event data", nil)
+		}
+		legacy, _ := json.Marshal(structs.AppointmentEventData{AppointmentID: req.AppointmentID})
+		if err := json.Unmarshal(legacy, out); err != nil {
+			return invalidEvent("Invalid event data", err)
+		}
+		return nil
+	}
+	if err := json.Unmarshal(req.Data, out); err != nil {
+		return invalidEvent("Invalid event data", err)
+	}
+	return nil
+}
+
+func validateRange(start, end time.Time) error {
+	if start.IsZero() || end.IsZero() || !end.After(start) {
+		return invalidEvent("start_time must be before end_time", nil)
+	}
+	return nil
+}
+
+type appointmentRecord struct {
+	TenantID   string
+	CoachID    string
+	CalendarID string
+	StartTime  time.Time
+	EndTime    time.Time
+	Status     string
+	BlockID    sql.NullString
+	// SeriesID and SeriesIndex are set for occurrences of a recurring booking.
+	SeriesID    sql.NullString
+	SeriesIndex sql.NullInt64
+}
+
+func (h *SchedulingHandler) loadAppointment(ctx context.Context, q db.Querier, appointmentID string) (*appointmentRecord, error) {
+	var a appointmentRecord
+	err := q.QueryRowContext(ctx, `
+SELECT tenant_id, coach_id, calendar_id, start_time, end_time, status, external_calendar_id,
+  series_id, series_index
+FROM coach_appointments WHERE id = $1`, appointmentID).Scan(
+		&a.TenantID, &a.CoachID, &a.CalendarID, &a.StartTime, &a.EndTime, &a.Status, &a.BlockID,
+		&a.SeriesID, &a.SeriesIndex)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &webhookError{status: http.StatusNotFound, code: "NOT_FOUND", message: "Appointment not found", err: err}
+	}
+	if err != nil {
+		return nil, databaseFailure(err)
+	}
+	return &a, nil
+}
+
+func (h *SchedulingHandler) handleAppointmentCancelled(ctx context.Context, req *structs.WebHookRequest) error {
+	var data structs.AppointmentEventData
+	if err := decodeEventData(req, &data); err != nil {
+		return err
+	}
+
+	appt, err := h.loadAppointment(ctx, h.Deps.DB, data.AppointmentID)
+	if err != nil {
+		return err
+	}
+	if appt.Status != "scheduled" {
+		return notScheduled("cancelled")
+	}
+
+	_, err = h.Deps.DB.ExecContext(ctx, `UPDATE coach_appointments SET webhook_attempts = webhook_attempts + 1,
+webhook_last_attempt = NOW() WHERE id = $1`, data.AppointmentID)
+	if err != nil {
+		return databaseFailure(err)
+	}
+
+	return h.cancelAppointment(ctx, data.AppointmentID, appt, data.Reason)
+}
+
+// cancelAppointment marks the appointment cancelled, frees its slots and
+// queues the CRM and calendar notifications. It is shared by the calendar
+// webhook and DELETE /api/appointments/{id}. The calendar block of a group
+// session is only released with its last seat; until then it is handed to a
+// seat still booked.
+func (h *SchedulingHandler) cancelAppointment(ctx context.Context, appointmentID string, appt *appointmentRecord, reason string) error {
+	tx, err := h.Deps.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return databaseFailure(err)
+	}
+	defer tx.Rollback()
+
+	heirID, err := handOffSessionBlock(ctx, tx, appointmentID, appt)
+	if err != nil {
+		return databaseFailure(err)
+	}
+
+	calendarEvent := sql.NullString{String: jobs.CalendarEventRelease, Valid: heirID == ""}
+	res, err := tx.ExecContext(ctx, `UPDATE coach_appointments SET status = 'cancelled', updated_at = NOW(), cancelled_at = NOW(),
+cancellation_reason = NULLIF($2, ''), crm_sync_status = 'pending', crm_sync_event = $3,
+crm_sync_attempts = 0, crm_last_error = NULL,
+calendar_sync_status = CASE WHEN $4::varchar IS NULL THEN NULL ELSE 'pending' END, calendar_sync_event = $4,
+calendar_sync_attempts = 0, calendar_last_error = NULL,
+external_calendar_id = CASE WHEN $4::varchar IS NULL THEN NULL ELSE external_calendar_id END
+WHERE id = $1 AND status = 'scheduled'`, appointmentID, reason, jobs.CRMEventCancelled, calendarEvent)
+	if err != nil {
+		return databaseFailure(err)
+	}
+	// A concurrent request changed the appointment since it was loaded.
+	if n, err := res.RowsAffected(); err != nil {
+		return databaseFailure(err)
+	} else if n == 0 {
+		return notScheduled("cancelled")
+	}
+
+	_, err = tx.ExecContext(ctx, freeSlotsQuery, appt.CoachID, appt.StartTime, appt.EndTime)
+	if err != nil {
+		return databaseFailure(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return databaseFailure(err)
+	}
+
+	if err := h.Deps.Reminders.Cancel(ctx, h.Deps.DB, appointmentID); err != nil {
+		log.Printf("cancelAppointment: failed to cancel reminders for %s: %v", appointmentID, err)
+	}
+
+	h.syncCRM(ctx, appointmentID)
+
+	if heirID != "" {
+		if err := h.Deps.CalendarSyncer.SyncNow(ctx, heirID); err != nil {
+			log.Printf("cancelAppointment: calendar block for %s queued for retry: %v", heirID, err)
+		}
+	} else if err := h.Deps.CalendarSyncer.SyncNow(ctx, appointmentID); err != nil {
+		log.Printf("cancelAppointment: calendar release for %s queued for retry: %v", appointmentID, err)
+	}
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		TenantID:   appt.TenantID,
+		Action:     structs.EventAppointmentCancelled,
+		EntityType: "appointment",
+		EntityID:   appointmentID,
+		Before:     appointmentSnapshot(appointmentID, appt, appt.Status),
+		After:      appointmentSnapshot(appointmentID, appt, "cancelled"),
+	})
+
+	h.publishAppointment(ctx, structs.EventAppointmentCancelled, appointmentID, appt, "cancelled")
+	return nil
+}
+
+// handOffSessionBlock passes the calendar block of a group session seat that
+// is being cancelled to another seat of the session still booked, and
+// returns that seat. It returns "" when the seat is the session's last, so
+// the block has to be released. The session's seats are locked so concurrent
+// cancellations agree on which one is last.
+func handOffSessionBlock(ctx context.Context, tx *sql.Tx, appointmentID string, appt *appointmentRecord) (string, error) {
+	rows, err := tx.QueryContext(ctx, `
+SELECT id FROM coach_appointments
+WHERE coach_id = $1 AND start_time = $2 AND status = 'scheduled'
+ORDER BY seat
+FOR UPDATE`, appt.CoachID, appt.StartTime)
+	if err != nil {
+		return "", err
+	}
+	var heirID string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return "", err
+		}
+		if id != appointmentID && heirID == "" {
+			heirID = id
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if heirID == "" {
+		return "", nil
+	}
+
+	// A seat that joined the session holds no block and has nothing to pass
+	// on; a block not yet made is passed on still pending.
+	_, err = tx.ExecContext(ctx, `
+UPDATE coach_appointments heir SET external_calendar_id = c.external_calendar_id,
+  calendar_sync_status = c.calendar_sync_status, calendar_sync_event = c.calendar_sync_event,
+  calendar_sync_attempts = 0, calendar_last_error = NULL, updated_at = NOW()
+FROM coach_appointments c
+WHERE heir.id = $1 AND c.id = $2 AND c.calendar_sync_event IS NOT NULL`, heirID, appointmentID)
+	if err != nil {
+		return "", err
+	}
+	return heirID, nil
+}
+
+func (h *SchedulingHandler) handleAppointmentConfirmed(ctx context.Context, req *structs.WebHookRequest) error {
+	var data structs.AppointmentEventData
+	if err := decodeEventData(req, &data); err != nil {
+		return err
+	}
+
+	appt, err := h.loadAppointment(ctx, h.Deps.DB, data.AppointmentID)
+	if err != nil {
+		return err
+	}
+	if appt.Status != "scheduled" {
+		return notScheduled("confirmed")
+	}
+
+	res, err := h.Deps.DB.ExecContext(ctx, `UPDATE coach_appointments SET updated_at = NOW(), confirmed_at = NOW(),
+webhook_attempts = webhook_attempts + 1, webhook_last_attempt = NOW(),
+crm_sync_status = 'pending', crm_sync_event = $2, crm_sync_attempts = 0, crm_last_error = NULL
+WHERE id = $1 AND status = 'scheduled'`, data.AppointmentID, jobs.CRMEventConfirmed)
+	if err != nil {
+		return databaseFailure(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return databaseFailure(err)
+	} else if n == 0 {
+		return notScheduled("confirmed")
+	}
+
+	h.syncCRM(ctx, data.AppointmentID)
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		TenantID:   appt.TenantID,
+		Action:     structs.EventAppointmentConfirmed,
+		EntityType: "appointment",
+		EntityID:   data.AppointmentID,
+		After:      appointmentSnapshot(data.AppointmentID, appt, appt.Status),
+	})
+
+	h.publishAppointment(ctx, structs.EventAppointmentConfirmed, data.AppointmentID, appt, appt.Status)
+	return nil
+}
+
+// handleAppointmentRescheduled moves an appointment to the time reported by
+// the calendar, freeing the old slots and taking the new ones in one
+// transaction, together with its reminders. The calendar already holds the
+// block, so it is not re-sent.
+func (h *SchedulingHandler) handleAppointmentRescheduled(ctx context.Context, req *structs.WebHookRequest) error {
+	var data structs.AppointmentRescheduledData
+	if err := decodeEventData(req, &data); err != nil {
+		return err
+	}
+	if err := validateRange(data.StartTime, data.EndTime); err != nil {
+		return err
+	}
+
+	tx, err := h.Deps.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return databaseFailure(err)
+	}
+	defer tx.Rollback()
+
+	appt, err := h.loadAppointment(ctx, tx, data.AppointmentID)
+	if err != nil {
+		return err
+	}
+	if appt.Status != "scheduled" {
+		return &webhookError{status: http.StatusConflict, code: "INVALID_STATE", message: "Only scheduled appointments can be rescheduled"}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE coach_appointments SET start_time = $2, end_time = $3, updated_at = NOW(),
+webhook_attempts = webhook_attempts + 1, webhook_last_attempt = NOW() WHERE id = $1`,
+		data.AppointmentID, data.StartTime.UTC(), data.EndTime.UTC())
+	if err != nil {
+		return &webhookError{status: http.StatusConflict, code: "NO_SLOT_ERROR", message: "Coach already has an appointment at the new time", err: err}
+	}
+
+	_, err = tx.ExecContext(ctx, freeSlotsQuery, appt.CoachID, appt.StartTime, appt.EndTime)
+	if err != nil {
+		return databaseFailure(err)
+	}
+
+	if err := setSlotsAvailability(ctx, tx, appt.CoachID, data.StartTime, data.EndTime, false); err != nil {
+		return databaseFailure(err)
+	}
+
+	if err := h.Deps.Reminders.Schedule(ctx, tx, data.AppointmentID); err != nil {
+		return databaseFailure(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return databaseFailure(err)
+	}
+
+	before := appointmentSnapshot(data.AppointmentID, appt, appt.Status)
+	appt.StartTime = data.StartTime.UTC()
+	appt.EndTime = data.EndTime.UTC()
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		TenantID:   appt.TenantID,
+		Action:     structs.EventAppointmentRescheduled,
+		EntityType: "appointment",
+		EntityID:   data.AppointmentID,
+		Before:     before,
+		After:      appointmentSnapshot(data.AppointmentID, appt, appt.Status),
+	})
+
+	h.publishAppointment(ctx, structs.EventAppointmentRescheduled, data.AppointmentID, appt, appt.Status)
+	return nil
+}
+
+func (h *SchedulingHandler) handleSlotBlocked(ctx context.Context, req *structs.WebHookRequest) error {
+	var data structs.SlotEventData
+	if err := decodeEventData(req, &data); err != nil {
+		return err
+	}
+	if data.CoachID == "" {
+		return invalidEvent("Missing coach_id", nil)
+	}
+	if err := validateRange(data.StartTime, data.EndTime); err != nil {
+		return err
+	}
+
+	if err := setSlotsAvailability(ctx, h.Deps.DB, data.CoachID, data.StartTime, data.EndTime, false); err != nil {
+		return databaseFailure(err)
+	}
+
+	// Appointments the coach already has in the blocked period need another
+	// coach; the Reassigner picks them up.
+	_, err := h.Deps.DB.ExecContext(ctx, `
+UPDATE coach_appointments SET needs_reassignment = true, reassignment_reason = 'slot_blocked', updated_at = NOW()
+WHERE coach_id = $1 AND status = 'scheduled' AND start_time > NOW()
+  AND start_time < $3 AND end_time > $2 AND NOT needs_reassignment`,
+		data.CoachID, data.StartTime.UTC(), data.EndTime.UTC())
+	if err != nil {
+		return databaseFailure(err)
+	}
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		TenantID:   h.coachTenant(ctx, data.CoachID),
+		Action:     structs.EventSlotBlocked,
+		EntityType: "coach",
+		EntityID:   data.CoachID,
+		After:      data,
+	})
+	return nil
+}
+
+func (h *SchedulingHandler) handleSlotReleased(ctx context.Context, req *structs.WebHookRequest) error {
+	var data structs.SlotEventData
+	if err := decodeEventData(req, &data); err != nil {
+		return err
+	}
+	if data.CoachID == "" {
+		return invalidEvent("Missing coach_id", nil)
+	}
+	if err := validateRange(data.StartTime, data.EndTime); err != nil {
+		return err
+	}
+
+	if err := setSlotsAvailability(ctx, h.Deps.DB, data.CoachID, data.StartTime, data.EndTime, true); err != nil {
+		return databaseFailure(err)
+	}
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		TenantID:   h.coachTenant(ctx, data.CoachID),
+		Action:     structs.EventSlotReleased,
+		EntityType: "coach",
+		EntityID:   data.CoachID,
+		After:      data,
+	})
+	return nil
+}
+
+func (h *SchedulingHandler) handleCoachSettingsChanged(ctx context.Context, req *structs.WebHookRequest) error {
+	var data structs.CoachSettingsChangedData
+	if err := decodeEventData(req, &data); err != nil {
+		return err
+	}
+	if data.CoachID == "" {
+		return invalidEvent("Missing coach_id", nil)
+	}
+
+	start, end, timezone, err := parseWorkingHours(data.WorkingHours)
+	if err != nil {
+		return err
+	}
+
+	var maxDaily sql.NullInt64
+	if data.MaxDailyAppointments != nil {
+		if *data.MaxDailyAppointments <= 0 {
+			return invalidEvent("max_daily_appointments must be positive", nil)
+		}
+		maxDaily = sql.NullInt64{Int64: int64(*data.MaxDailyAppointments), Valid: true}
+	}
+
+	before, err := h.loadCoachSettings(ctx, data.CoachID)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.Deps.DB.ExecContext(ctx, `
+UPDATE coaches SET
+  working_hours_start = COALESCE($2::time, working_hours_start),
+  working_hours_end = COALESCE($3::time, working_hours_end),
+  timezone = COALESCE($4, timezone),
+  max_daily_appointments = COALESCE($5, max_daily_appointments)
+WHERE id = $1`, data.CoachID, start, end, timezone, maxDaily)
+	if err != nil {
+		return databaseFailure(err)
+	}
+
+	after, err := h.loadCoachSettings(ctx, data.CoachID)
+	if err != nil {
+		return err
+	}
+
+	h.Deps.Audit.Record(ctx, audit.Entry{
+		TenantID:   before.tenantID,
+		Action:     structs.EventCoachSettingsChanged,
+		EntityType: "coach",
+		EntityID:   data.CoachID,
+		Before:     before,
+		After:      after,
+	})
+	return nil
+}
+
+// coachSettings is the audit snapshot of a coach's scheduling settings.
+type coachSettings struct {
+	tenantID             string
+	WorkingHours         structs.WorkingHours `json:"working_hours"`
+	MaxDailyAppointments int                  `json:"max_daily_appointments"`
+}
+
+func (h *SchedulingHandler) loadCoachSettings(ctx context.Context, coachID string) (*coachSettings, error) {
+	var c coachSettings
+	err := h.Deps.DB.QueryRowContext(ctx, `
+SELECT tenant_id, to_char(working_hours_start, 'HH24:MI'), to_char(working_hours_end, 'HH24:MI'), timezone, max_daily_appointments
+FROM coaches WHERE id = $1`, coachID).Scan(
+		&c.tenantID, &c.WorkingHours.Start, &c.WorkingHours.End, &c.WorkingHours.Timezone, &c.MaxDailyAppointments)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &webhookError{status: http.StatusNotFound, code: "NOT_FOUND", message: "Coach not found", err: err}
+	}
+	if err != nil {
+		return nil, databaseFailure(err)
+	}
+	return &c, nil
+}
+
+// coachTenant returns the coach's tenant, or "" if it can't be read.
+func (h *SchedulingHandler) coachTenant(ctx context.Context, coachID string) string {
+	var tenantID string
+	if err := h.Deps.DB.QueryRowContext(ctx, `SELECT tenant_id FROM coaches WHERE id = $1`, coachID).Scan(&tenantID); err != nil {
+		log.Printf("coachTenant: coach %s: %v", coachID, err)
+	}
+	return tenantID
+}
+
+// syncCRM notifies the CRM of the appointment's pending state change. A CRM
+// failure does not fail the webhook: the row stays unsynced and the
+// CRMSyncer retries it in the background.
+func (h *SchedulingHandler) syncCRM(ctx context.Context, appointmentID string) {
+	if err := h.Deps.CRMSyncer.SyncNow(ctx, appointmentID); err != nil {
+		log.Printf("syncCRM: appointment %s queued for retry: %v", appointmentID, err)
+	}
+}
+
+func appointmentSnapshot(appointmentID string, appt *appointmentRecord, status string) structs.AppointmentEvent {
+	return structs.AppointmentEvent{
+		AppointmentID: appointmentID,
+		CoachID:       appt.CoachID,
+		CalendarID:    appt.CalendarID,
+		StartTime:     appt.StartTime.UTC(),
+		EndTime:       appt.EndTime.UTC(),
+		Status:        status,
+	}
+}
+
+func (h *SchedulingHandler) publishAppointment(ctx context.Context, eventType, appointmentID string, appt *appointmentRecord, status string) {
+	h.Deps.Publisher.Publish(ctx, appt.TenantID, eventType, appointmentSnapshot(appointmentID, appt, status))
+}
+
+// publishIfCapacityReached emits coach.capacity_reached once a booking fills
+// the coach's max_daily_appointments for day (YYYY-MM-DD).
+func (h *SchedulingHandler) publishIfCapacityReached(ctx context.Context, coachID string, day string) {
+	var tenantID string
+	var appointments, maxDaily int
+	err := h.Deps.DB.QueryRowContext(ctx, `
+SELECT c.tenant_id, COUNT(DISTINCT ca.start_time), c.max_daily_appointments
+FROM coaches c
+LEFT JOIN coach_appointments ca ON ca.coach_id = c.id
+  AND ca.start_time::date = $2::date
+  AND ca.status = 'scheduled'
+WHERE c.id = $1
+GROUP BY c.tenant_id, c.max_daily_appointments`, coachID, day).Scan(&tenantID, &appointments, &maxDaily)
+	if err != nil {
+		log.Printf("publishIfCapacityReached: coach %s: %v", coachID, err)
+		return
+	}
+
+	if appointments == maxDaily {
+		h.Deps.Publisher.Publish(ctx, tenantID, structs.EventCoachCapacityReached, structs.CoachCapacityEvent{
+			CoachID:              coachID,
+			Date:                 day,
+			Appointments:         appointments,
+			MaxDailyAppointments: maxDaily,
+		})
+	}
+}
+
+// freeSlotsQuery makes the coach's slots between $2 and $3 available again,
+// except those overlapping the coach's time off or still held by a scheduled
+// appointment, such as the other seats of a group session.
+const freeSlotsQuery = `UPDATE coach_slots SET available = true, updated_at = NOW() WHERE coach_id = $1
+AND start_time >= $2 AND start_time < $3
+AND NOT EXISTS (
+  SELECT 1 FROM coach_time_off t
+  WHERE t.coach_id = coach_slots.coach_id
+    AND t.start_time < coach_slots.start_time + interval '15 minutes'
+    AND t.end_time > coach_slots.start_time
+)
+AND NOT EXISTS (
+  SELECT 1 FROM coach_appointments ca
+  WHERE ca.coach_id = coach_slots.coach_id
+    AND ca.start_time < coach_slots.start_time + interval '15 minutes'
+    AND ca.end_time > coach_slots.start_time
+    AND ca.status = 'scheduled'
+)`
+
+// setSlotsAvailability upserts every 15-minute slot of the coach between start
+// and end. Slots held by a scheduled appointment or overlapping the coach's
+// time off are never made available.
+func setSlotsAvailability(ctx context.Context, q db.Querier, coachID string, start, end time.Time, available bool) error {
+	for _, st := range splitInto15MinStarts(start, end) {
+		_, err := q.ExecContext(ctx, `
+INSERT INTO coach_slots (coach_id, start_time, available)
+SELECT $1, $2, $3::boolean AND NOT EXISTS (
+  SELECT 1 FROM coach_appointments ca
+  WHERE ca.coach_id = $1
+    AND ca.start_time <= $2
+    AND ca.end_time > $2
+    AND ca.status = 'scheduled'
+) AND NOT EXISTS (
+  SELECT 1 FROM coach_time_off t
+  WHERE t.coach_id = $1
+    AND t.start_time < $2::timestamptz + interval '15 minutes'
+    AND t.end_time > $2
+)
+ON CONFLICT (coach_id, start_time) DO UPDATE
+  SET available = EXCLUDED.available,
+  updated_at = NOW()
+`, coachID, st, available)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/signing"
+)
+
+// signatureLedger remembers calendar webhook signatures for the replay check.
+// *db.RedisClient implements it.
+type signatureLedger interface {
+	MarkSignatureSeen(signature string, ttl time.Duration) (bool, error)
+	ReleaseSignature(signature string) error
+}
+
+// claimSignature records signature for ttl and reports whether it had
+// already been used. release gives an unprocessed request's signature back.
+// If the ledger is unavailable the request is let through.
+func claimSignature(ledger signatureLedger, signature string, ttl time.Duration) (replayed bool, release func()) {
+	replayed, err := ledger.MarkSignatureSeen(signature, ttl)
+	if err != nil {
+		log.Printf("WebhookHandler: failed to record signature: %v", err)
+		return false, func() {}
+	}
+	if replayed {
+		return true, func() {}
+	}
+	return false, func() {
+		if err := ledger.ReleaseSignature(signature); err != nil {
+			log.Printf("WebhookHandler: failed to release signature: %v", err)
+		}
+	}
+}
+
+// verifyAndClaim authenticates a calendar webhook's raw body and claims its
+// signature for as long as Verify would accept it. A replayed signature is
+// reported rather than rejected so the caller can still answer a retry under
+// the same idempotency key.
+func verifyAndClaim(verifier *signing.Verifier, ledger signatureLedger, header http.Header, body []byte) (replayed bool, release func(), err error) {
+	if err := verifier.Verify(calendarWebhookSource, header, body); err != nil {
+		return false, nil, err
+	}
+	replayed, release = claimSignature(ledger, header.Get(signing.SignatureHeader), verifier.ReplayWindow())
+	return replayed, release, nil
+}
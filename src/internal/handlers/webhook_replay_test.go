@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/signing"
+)
+
+type memoryLedger struct {
+	seen map[string]time.Duration
+	err  error
+}
+
+func (l *memoryLedger) MarkSignatureSeen(signature string, ttl time.Duration) (bool, error) {
+	if l.err != nil {
+		return false, l.err
+	}
+	if _, ok := l.seen[signature]; ok {
+		return true, nil
+	}
+	l.seen[signature] = ttl
+	return false, nil
+}
+
+func (l *memoryLedger) ReleaseSignature(signature string) error {
+	delete(l.seen, signature)
+	return nil
+}
+
+const testWebhookSecret = "whsec-test"
+
+var testWebhookBody = []byte(`{"event_type":"slot.released","data":{"coach_id":"coach-1"}}`)
+
+func newTestVerifier(tolerance time.Duration) *signing.Verifier {
+	return signing.NewVerifier(map[string][]string{calendarWebhookSource: {testWebhookSecret}}, tolerance)
+}
+
+// signedHeader signs testWebhookBody the way the Calendar API does, dated at.
+func signedHeader(at time.Time) http.Header {
+	h := http.Header{}
+	h.Set(signing.TimestampHeader, strconv.FormatInt(at.Unix(), 10))
+	h.Set(signing.SignatureHeader, signing.Sign(testWebhookSecret, at.Unix(), testWebhookBody))
+	return h
+}
+
+func TestVerifyAndClaimRejectsReplay(t *testing.T) {
+	verifier := newTestVerifier(5 * time.Minute)
+	ledger := &memoryLedger{seen: map[string]time.Duration{}}
+	header := signedHeader(time.Now())
+
+	if replayed, _, err := verifyAndClaim(verifier, ledger, header, testWebhookBody); err != nil || replayed {
+		t.Fatalf("first delivery: replayed=%v err=%v", replayed, err)
+	}
+	if replayed, _, err := verifyAndClaim(verifier, ledger, header, testWebhookBody); err != nil || !replayed {
+		t.Fatalf("replay: replayed=%v err=%v, want a replay", replayed, err)
+	}
+	if replayed, _, err := verifyAndClaim(verifier, ledger, signedHeader(time.Now().Add(time.Second)), testWebhookBody); err != nil || replayed {
+		t.Fatalf("re-signed delivery: replayed=%v err=%v", replayed, err)
+	}
+}
+
+func TestVerifyAndClaimRemembersFutureDatedSignatures(t *testing.T) {
+	tolerance := 5 * time.Minute
+	verifier := newTestVerifier(tolerance)
+	ledger := &memoryLedger{seen: map[string]time.Duration{}}
+
+	// Verify accepts this until about 2×tolerance from now, so the ledger
+	// must hold it at least that long.
+	future := time.Now().Add(tolerance - time.Second)
+	header := signedHeader(future)
+	if _, _, err := verifyAndClaim(verifier, ledger, header, testWebhookBody); err != nil {
+		t.Fatalf("future-dated delivery inside the tolerance rejected: %v", err)
+	}
+
+	ttl := ledger.seen[header.Get(signing.SignatureHeader)]
+	if acceptedFor := time.Until(future.Add(tolerance)); ttl < acceptedFor {
+		t.Fatalf("signature remembered for %v, but Verify accepts it for %v", ttl, acceptedFor)
+	}
+}
+
+func TestVerifyAndClaimRejectsBadSignature(t *testing.T) {
+	verifier := newTestVerifier(5 * time.Minute)
+	ledger := &memoryLedger{seen: map[string]time.Duration{}}
+
+	header := signedHeader(time.Now())
+	tampered := []byte(`{"event_type":"slot.released","data":{"coach_id":"coach-2"}}`)
+	if _, _, err := verifyAndClaim(verifier, ledger, header, tampered); !errors.Is(err, signing.ErrInvalidSignature) {
+		t.Fatalf("tampered body: err=%v, want %v", err, signing.ErrInvalidSignature)
+	}
+	if _, _, err := verifyAndClaim(verifier, ledger, signedHeader(time.Now().Add(-time.Hour)), testWebhookBody); !errors.Is(err, signing.ErrExpiredTimestamp) {
+		t.Fatalf("stale timestamp: err=%v, want %v", err, signing.ErrExpiredTimestamp)
+	}
+	if len(ledger.seen) != 0 {
+		t.Fatalf("rejected requests claimed signatures: %v", ledger.seen)
+	}
+}
+
+func TestVerifyAndClaimReleaseAllowsRetry(t *testing.T) {
+	verifier := newTestVerifier(5 * time.Minute)
+	ledger := &memoryLedger{seen: map[string]time.Duration{}}
+	header := signedHeader(time.Now())
+
+	_, release, _ := verifyAndClaim(verifier, ledger, header, testWebhookBody)
+	release() // processing failed
+
+	if replayed, _, err := verifyAndClaim(verifier, ledger, header, testWebhookBody); err != nil || replayed {
+		t.Fatalf("retry after a failed attempt: replayed=%v err=%v", replayed, err)
+	}
+}
+
+func TestVerifyAndClaimReleaseKeepsOthers(t *testing.T) {
+	verifier := newTestVerifier(5 * time.Minute)
+	ledger := &memoryLedger{seen: map[string]time.Duration{}}
+	header := signedHeader(time.Now())
+
+	verifyAndClaim(verifier, ledger, header, testWebhookBody)
+	replayed, release, _ := verifyAndClaim(verifier, ledger, header, testWebhookBody)
+	if !replayed {
+		t.Fatal("second use not reported as a replay")
+	}
+	release() // a rejected replay must not free the original claim
+
+	if replayed, _, _ := verifyAndClaim(verifier, ledger, header, testWebhookBody); !replayed {
+		t.Fatal("releasing a replay freed the signature")
+	}
+}
+
+func TestVerifyAndClaimLedgerDown(t *testing.T) {
+	verifier := newTestVerifier(5 * time.Minute)
+	ledger := &memoryLedger{seen: map[string]time.Duration{}, err: errors.New("connection refused")}
+
+	if replayed, _, err := verifyAndClaim(verifier, ledger, signedHeader(time.Now()), testWebhookBody); err != nil || replayed {
+		t.Fatalf("request rejected while the ledger is unavailable: replayed=%v err=%v", replayed, err)
+	}
+}
@@ -0,0 +1,179 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/breaker"
+	"github.com/transistxr/coach-assignment-server/src/internal/clients"
+	"github.com/transistxr/coach-assignment-server/src/internal/retry"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// Calendar sync events recorded in coach_appointments.calendar_sync_event.
+const (
+	CalendarEventBlock   = "block"
+	CalendarEventRelease = "release"
+	// CalendarEventMove releases the previous coach's block and blocks the
+	// slot for the current coach.
+	CalendarEventMove = "move"
+)
+
+// CalendarSyncer mirrors appointments into the external calendar: it blocks
+// the slot of a new appointment, releases it on cancellation and moves it
+// when the appointment is reassigned. It follows
+// the same outbox pattern as CRMSyncer, so bookings and cancellations go
+// through while the Calendar API is unavailable.
+type CalendarSyncer struct {
+	DB                 *sql.DB
+	AvailabilityClient *clients.AvailabilityClient
+	Interval           time.Duration
+	MaxAttempts        int
+}
+
+func NewCalendarSyncer(sqlDB *sql.DB, availabilityClient *clients.AvailabilityClient) *CalendarSyncer {
+	interval := time.Minute
+	if v, err := strconv.Atoi(os.Getenv("CALENDAR_SYNC_INTERVAL_SECONDS")); err == nil && v > 0 {
+		interval = time.Duration(v) * time.Second
+	}
+
+	maxAttempts := 10
+	if v, err := strconv.Atoi(os.Getenv("CALENDAR_SYNC_MAX_ATTEMPTS")); err == nil && v > 0 {
+		maxAttempts = v
+	}
+
+	return &CalendarSyncer{
+		DB:                 sqlDB,
+		AvailabilityClient: availabilityClient,
+		Interval:           interval,
+		MaxAttempts:        maxAttempts,
+	}
+}
+
+// Sync applies the appointment's pending calendar event and records the
+// outcome on the appointment row. calendar_sync_attempts counts the failed
+// attempts at the current event.
+func (s *CalendarSyncer) Sync(ctx context.Context, appointmentID string) error {
+	var event, coachID string
+	var startTime, endTime time.Time
+	var blockID, previousCoachID sql.NullString
+	err := s.DB.QueryRowContext(ctx, `
+SELECT calendar_sync_event, coach_id, start_time, end_time, external_calendar_id, previous_coach_id
+FROM coach_appointments
+WHERE id = $1 AND calendar_sync_status IN ('pending', 'failed')`, appointmentID).Scan(
+		&event, &coachID, &startTime, &endTime, &blockID, &previousCoachID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch event {
+	case CalendarEventBlock:
+		var blockResp *structs.BlockSlotResponse
+		blockResp, err = s.AvailabilityClient.BlockSlot(ctx, coachID, startTime, endTime)
+		if err == nil {
+			blockID = sql.NullString{String: blockResp.BlockId, Valid: blockResp.BlockId != ""}
+		}
+	case CalendarEventRelease:
+		// Nothing to release if the slot was never blocked.
+		if blockID.Valid {
+			_, err = s.AvailabilityClient.ReleaseSlot(ctx, coachID, blockID.String, startTime, endTime)
+		}
+	case CalendarEventMove:
+		if blockID.Valid && previousCoachID.Valid {
+			_, err = s.AvailabilityClient.ReleaseSlot(ctx, previousCoachID.String, blockID.String, startTime, endTime)
+			if err == nil {
+				// Forget the old block so a retry after a failed block below
+				// doesn't release it twice.
+				blockID = sql.NullString{}
+				_, err = s.DB.ExecContext(ctx, `UPDATE coach_appointments SET external_calendar_id = NULL
+WHERE id = $1 AND calendar_sync_event = $2`, appointmentID, event)
+			}
+		}
+		if err == nil {
+			var blockResp *structs.BlockSlotResponse
+			blockResp, err = s.AvailabilityClient.BlockSlot(ctx, coachID, startTime, endTime)
+			if err == nil {
+				blockID = sql.NullString{String: blockResp.BlockId, Valid: blockResp.BlockId != ""}
+			}
+		}
+	default:
+		err = fmt.Errorf("unknown calendar sync event %q", event)
+	}
+
+	if errors.Is(err, breaker.ErrOpen) {
+		return err
+	}
+
+	if err != nil {
+		_, dbErr := s.DB.ExecContext(ctx, `
+UPDATE coach_appointments SET calendar_sync_status = 'failed', calendar_sync_attempts = calendar_sync_attempts + 1,
+calendar_last_attempt = NOW(), calendar_last_error = $2
+WHERE id = $1 AND calendar_sync_event = $3`, appointmentID, err.Error(), event)
+		if dbErr != nil {
+			log.Printf("CalendarSyncer: failed to record calendar failure for %s: %v", appointmentID, dbErr)
+		}
+		return err
+	}
+
+	_, err = s.DB.ExecContext(ctx, `
+UPDATE coach_appointments SET calendar_sync_status = 'synced',
+calendar_last_attempt = NOW(), calendar_last_error = NULL, external_calendar_id = $3
+WHERE id = $1 AND calendar_sync_event = $2`, appointmentID, event, blockID)
+	return err
+}
+
+// SyncNow makes a single attempt at Sync for callers serving a request; a
+// failure is left to Run instead of holding the request through backoff.
+func (s *CalendarSyncer) SyncNow(ctx context.Context, appointmentID string) error {
+	return s.Sync(retry.SingleAttempt(ctx), appointmentID)
+}
+
+// Run retries unsynced appointments every Interval until ctx is done.
+func (s *CalendarSyncer) Run(ctx context.Context) {
+	runEvery(ctx, "CalendarSyncer", s.Interval, s.syncDue)
+}
+
+func (s *CalendarSyncer) syncDue(ctx context.Context) {
+	rows, err := s.DB.QueryContext(ctx, `
+UPDATE coach_appointments SET calendar_last_attempt = NOW()
+WHERE id IN (
+  SELECT id FROM coach_appointments
+  WHERE calendar_sync_status IN ('pending', 'failed')
+    AND calendar_sync_attempts < $1
+    AND COALESCE(calendar_last_attempt, updated_at) < NOW() - make_interval(secs => $2)
+  ORDER BY updated_at
+  LIMIT 50
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id`, s.MaxAttempts, s.Interval.Seconds())
+	if err != nil {
+		log.Printf("CalendarSyncer: failed to claim appointments: %v", err)
+		return
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("CalendarSyncer: scan appointment id: %v", err)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := s.Sync(ctx, id); err != nil {
+			log.Printf("CalendarSyncer: appointment %s still not synced: %v", id, err)
+		}
+	}
+}
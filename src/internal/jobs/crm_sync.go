@@ -0,0 +1,202 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/breaker"
+	"github.com/transistxr/coach-assignment-server/src/internal/clients"
+	"github.com/transistxr/coach-assignment-server/src/internal/retry"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// CRM sync events recorded in coach_appointments.crm_sync_event.
+const (
+	CRMEventCreated   = "created"
+	CRMEventCancelled = "cancelled"
+	CRMEventConfirmed = "confirmed"
+	// CRMEventReassigned tells the CRM the appointment moved to another coach.
+	CRMEventReassigned = "reassigned"
+)
+
+// CRMSyncer tells the CRM about new appointments and their state changes.
+// Handlers mark the appointment row 'pending' in the same statement that
+// changes its state and then call Sync; anything that fails or never gets sent
+// is picked up again by Run, so a CRM outage only delays the notification.
+type CRMSyncer struct {
+	DB          *sql.DB
+	CRMClient   *clients.CRMClient
+	Interval    time.Duration
+	MaxAttempts int
+}
+
+func NewCRMSyncer(sqlDB *sql.DB, crmClient *clients.CRMClient) *CRMSyncer {
+	interval := time.Minute
+	if v, err := strconv.Atoi(os.Getenv("CRM_SYNC_INTERVAL_SECONDS")); err == nil && v > 0 {
+		interval = time.Duration(v) * time.Second
+	}
+
+	maxAttempts := 10
+	if v, err := strconv.Atoi(os.Getenv("CRM_SYNC_MAX_ATTEMPTS")); err == nil && v > 0 {
+		maxAttempts = v
+	}
+
+	return &CRMSyncer{
+		DB:          sqlDB,
+		CRMClient:   crmClient,
+		Interval:    interval,
+		MaxAttempts: maxAttempts,
+	}
+}
+
+// Sync sends the appointment's pending CRM event and records the outcome on
+// the appointment row. The idempotency key is derived from the appointment and
+// event so retries are deduplicated by the CRM. crm_sync_attempts counts the
+// failed attempts at the current event.
+func (s *CRMSyncer) Sync(ctx context.Context, appointmentID string) error {
+	var event string
+	var reason, previousCoachID sql.NullString
+	var syncedAt sql.NullTime
+	created := &structs.AppointmentCreatedRequest{AppointmentID: appointmentID}
+	err := s.DB.QueryRowContext(ctx, `
+SELECT crm_sync_event, cancellation_reason, coach_id, start_time, end_time, contact_id, previous_coach_id,
+  crm_synced_at
+FROM coach_appointments
+WHERE id = $1 AND crm_sync_status IN ('pending', 'failed')`, appointmentID).Scan(
+		&event, &reason, &created.CoachID, &created.StartTime, &created.EndTime, &created.ClientID, &previousCoachID,
+		&syncedAt)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	idemKey := fmt.Sprintf("%s:%s", appointmentID, event)
+	if event == CRMEventReassigned {
+		// An appointment can be reassigned more than once.
+		idemKey = fmt.Sprintf("%s:%s:%s", appointmentID, event, created.CoachID)
+	}
+
+	var crmID sql.NullString
+	if event != CRMEventCreated && !syncedAt.Valid {
+		// The CRM never acknowledged the appointment: a later event replaced
+		// 'created' before it went out. Send the creation first, under its own
+		// idempotency key, so the CRM knows the record the event is about.
+		var resp *structs.AppointmentCreatedResponse
+		resp, err = s.CRMClient.SendAppointmentCreated(ctx, created, fmt.Sprintf("%s:%s", appointmentID, CRMEventCreated))
+		if err == nil {
+			crmID = sql.NullString{String: resp.CrmID, Valid: resp.CrmID != ""}
+		}
+	}
+
+	if err == nil {
+		switch event {
+		case CRMEventCreated:
+			var resp *structs.AppointmentCreatedResponse
+			resp, err = s.CRMClient.SendAppointmentCreated(ctx, created, idemKey)
+			if err == nil {
+				crmID = sql.NullString{String: resp.CrmID, Valid: resp.CrmID != ""}
+			}
+		case CRMEventCancelled:
+			_, err = s.CRMClient.SendAppointmentCancelled(ctx, &structs.AppointmentCancelledRequest{
+				AppointmentID: appointmentID,
+				Reason:        reason.String,
+			}, idemKey)
+		case CRMEventConfirmed:
+			_, err = s.CRMClient.SendAppointmentUpdated(ctx, &structs.AppointmentUpdatedRequest{
+				AppointmentID: appointmentID,
+				Status:        CRMEventConfirmed,
+			}, idemKey)
+		case CRMEventReassigned:
+			_, err = s.CRMClient.SendAppointmentUpdated(ctx, &structs.AppointmentUpdatedRequest{
+				AppointmentID:   appointmentID,
+				Status:          CRMEventReassigned,
+				CoachID:         created.CoachID,
+				PreviousCoachID: previousCoachID.String,
+			}, idemKey)
+		default:
+			err = fmt.Errorf("unknown CRM sync event %q", event)
+		}
+	}
+
+	// An open breaker means nothing was sent, so it doesn't use up an attempt.
+	if errors.Is(err, breaker.ErrOpen) {
+		return err
+	}
+
+	if err != nil {
+		_, dbErr := s.DB.ExecContext(ctx, `
+UPDATE coach_appointments SET crm_sync_status = 'failed', crm_sync_attempts = crm_sync_attempts + 1,
+crm_last_attempt = NOW(), crm_last_error = $2
+WHERE id = $1 AND crm_sync_event = $3`, appointmentID, err.Error(), event)
+		if dbErr != nil {
+			log.Printf("CRMSyncer: failed to record CRM failure for %s: %v", appointmentID, dbErr)
+		}
+		return err
+	}
+
+	// Only mark synced if no newer state change replaced the event meanwhile.
+	_, err = s.DB.ExecContext(ctx, `
+UPDATE coach_appointments SET crm_sync_status = 'synced',
+crm_last_attempt = NOW(), crm_last_error = NULL, crm_synced_at = NOW(),
+crm_contact_id = COALESCE($3, crm_contact_id)
+WHERE id = $1 AND crm_sync_event = $2`, appointmentID, event, crmID)
+	return err
+}
+
+// SyncNow makes a single attempt at Sync for callers serving a request; a
+// failure is left to Run instead of holding the request through backoff.
+func (s *CRMSyncer) SyncNow(ctx context.Context, appointmentID string) error {
+	return s.Sync(retry.SingleAttempt(ctx), appointmentID)
+}
+
+// Run retries unsynced appointments every Interval until ctx is done.
+func (s *CRMSyncer) Run(ctx context.Context) {
+	runEvery(ctx, "CRMSyncer", s.Interval, s.syncDue)
+}
+
+// syncDue claims appointments whose CRM event failed, or was left pending by
+// a request that never finished, and re-sends them. Claiming bumps
+// crm_last_attempt under SKIP LOCKED so replicas don't send the same rows.
+func (s *CRMSyncer) syncDue(ctx context.Context) {
+	rows, err := s.DB.QueryContext(ctx, `
+UPDATE coach_appointments SET crm_last_attempt = NOW()
+WHERE id IN (
+  SELECT id FROM coach_appointments
+  WHERE crm_sync_status IN ('pending', 'failed')
+    AND crm_sync_attempts < $1
+    AND COALESCE(crm_last_attempt, updated_at) < NOW() - make_interval(secs => $2)
+  ORDER BY updated_at
+  LIMIT 50
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id`, s.MaxAttempts, s.Interval.Seconds())
+	if err != nil {
+		log.Printf("CRMSyncer: failed to claim appointments: %v", err)
+		return
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("CRMSyncer: scan appointment id: %v", err)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := s.Sync(ctx, id); err != nil {
+			log.Printf("CRMSyncer: appointment %s still not synced: %v", id, err)
+		}
+	}
+}
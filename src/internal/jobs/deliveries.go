@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/events"
+)
+
+// DeliveryRedriver re-sends subscriber deliveries that failed, or were left
+// pending by a restart while Publisher was sending them. A delivery is given
+// up once it has used MaxAttempts HTTP attempts in total.
+type DeliveryRedriver struct {
+	DB          *sql.DB
+	Publisher   *events.Publisher
+	Interval    time.Duration
+	MaxAttempts int
+}
+
+func NewDeliveryRedriver(sqlDB *sql.DB, publisher *events.Publisher) *DeliveryRedriver {
+	interval := 5 * time.Minute
+	if v, err := strconv.Atoi(os.Getenv("WEBHOOK_REDELIVERY_INTERVAL_SECONDS")); err == nil && v > 0 {
+		interval = time.Duration(v) * time.Second
+	}
+
+	maxAttempts := 20
+	if v, err := strconv.Atoi(os.Getenv("WEBHOOK_DELIVERY_MAX_ATTEMPTS")); err == nil && v > 0 {
+		maxAttempts = v
+	}
+
+	return &DeliveryRedriver{
+		DB:          sqlDB,
+		Publisher:   publisher,
+		Interval:    interval,
+		MaxAttempts: maxAttempts,
+	}
+}
+
+// Run re-sends due deliveries every Interval until ctx is done.
+func (d *DeliveryRedriver) Run(ctx context.Context) {
+	runEvery(ctx, "DeliveryRedriver", d.Interval, d.redeliverDue)
+}
+
+// redeliverDue claims deliveries not attempted for an interval by bumping
+// last_attempt under SKIP LOCKED, so replicas don't send the same rows.
+func (d *DeliveryRedriver) redeliverDue(ctx context.Context) {
+	rows, err := d.DB.QueryContext(ctx, `
+UPDATE webhook_deliveries SET last_attempt = NOW()
+WHERE id IN (
+  SELECT id FROM webhook_deliveries
+  WHERE status IN ('pending', 'failed')
+    AND attempts < $1
+    AND COALESCE(last_attempt, created_at) < NOW() - make_interval(secs => $2)
+  ORDER BY created_at
+  LIMIT 50
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id`, d.MaxAttempts, d.Interval.Seconds())
+	if err != nil {
+		log.Printf("DeliveryRedriver: failed to claim deliveries: %v", err)
+		return
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("DeliveryRedriver: scan delivery id: %v", err)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := d.Publisher.Deliver(ctx, id); err != nil {
+			log.Printf("DeliveryRedriver: delivery %s still not delivered: %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/audit"
+	"github.com/transistxr/coach-assignment-server/src/internal/events"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// OutcomeRecorder marks appointments that ended more than Grace ago and are
+// still scheduled as completed. The grace period leaves coaches time to mark
+// a no-show first. Appointments still waiting for reassignment are left
+// alone: their coach was unavailable, so nobody knows whether they happened.
+type OutcomeRecorder struct {
+	DB        *sql.DB
+	Audit     *audit.Logger
+	Publisher *events.Publisher
+	Grace     time.Duration
+	Interval  time.Duration
+	BatchSize int
+}
+
+func NewOutcomeRecorder(sqlDB *sql.DB, auditLogger *audit.Logger, publisher *events.Publisher) *OutcomeRecorder {
+	interval := 5 * time.Minute
+	if v, err := strconv.Atoi(os.Getenv("OUTCOME_INTERVAL_SECONDS")); err == nil && v > 0 {
+		interval = time.Duration(v) * time.Second
+	}
+
+	grace := time.Hour
+	if v, err := strconv.Atoi(os.Getenv("OUTCOME_GRACE_MINUTES")); err == nil && v >= 0 {
+		grace = time.Duration(v) * time.Minute
+	}
+
+	return &OutcomeRecorder{
+		DB:        sqlDB,
+		Audit:     auditLogger,
+		Publisher: publisher,
+		Grace:     grace,
+		Interval:  interval,
+		BatchSize: 500,
+	}
+}
+
+// Run completes due appointments every Interval until ctx is done.
+func (o *OutcomeRecorder) Run(ctx context.Context) {
+	runEvery(ctx, "OutcomeRecorder", o.Interval, func(ctx context.Context) {
+		n, err := o.CompleteDue(ctx)
+		if err != nil {
+			log.Printf("OutcomeRecorder: %v", err)
+			return
+		}
+		if n > 0 {
+			log.Printf("OutcomeRecorder: marked %d appointments completed", n)
+		}
+	})
+}
+
+// CompleteDue marks up to BatchSize due appointments completed and returns
+// how many it changed.
+func (o *OutcomeRecorder) CompleteDue(ctx context.Context) (int, error) {
+	rows, err := o.DB.QueryContext(ctx, `
+UPDATE coach_appointments SET status = 'completed', outcome_recorded_at = NOW(), outcome_source = 'auto', updated_at = NOW()
+WHERE id IN (
+  SELECT id FROM coach_appointments
+  WHERE status = 'scheduled' AND NOT needs_reassignment AND end_time < NOW() - make_interval(secs => $1)
+  ORDER BY end_time
+  LIMIT $2
+  FOR UPDATE SKIP LOCKED
+) AND status = 'scheduled'
+RETURNING id, tenant_id, coach_id, calendar_id, start_time, end_time`, o.Grace.Seconds(), o.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("complete appointments: %w", err)
+	}
+
+	type completed struct {
+		tenantID string
+		event    structs.AppointmentEvent
+	}
+	var done []completed
+	for rows.Next() {
+		c := completed{event: structs.AppointmentEvent{Status: "completed"}}
+		if err := rows.Scan(&c.event.AppointmentID, &c.tenantID, &c.event.CoachID, &c.event.CalendarID,
+			&c.event.StartTime, &c.event.EndTime); err != nil {
+			log.Printf("OutcomeRecorder: scan appointment: %v", err)
+			continue
+		}
+		done = append(done, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return len(done), fmt.Errorf("complete appointments: %w", err)
+	}
+
+	for _, c := range done {
+		before := c.event
+		before.Status = "scheduled"
+		o.Audit.Record(ctx, audit.Entry{
+			TenantID:   c.tenantID,
+			Action:     structs.EventAppointmentCompleted,
+			EntityType: "appointment",
+			EntityID:   c.event.AppointmentID,
+			Before:     before,
+			After:      c.event,
+		})
+		o.Publisher.Publish(ctx, c.tenantID, structs.EventAppointmentCompleted, c.event)
+	}
+	return len(done), nil
+}
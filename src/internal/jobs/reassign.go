@@ -0,0 +1,235 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/assignment"
+	"github.com/transistxr/coach-assignment-server/src/internal/audit"
+	"github.com/transistxr/coach-assignment-server/src/internal/events"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// Reassigner moves future appointments flagged with needs_reassignment (coach
+// deactivated, on time off, or their calendar blocked the slot) to another
+// coach on the same calendar, chosen with the booking rules. Appointments no
+// coach can take stay flagged with the reason in reassignment_error and are
+// retried on the next run.
+type Reassigner struct {
+	DB             *sql.DB
+	CRMSyncer      *CRMSyncer
+	CalendarSyncer *CalendarSyncer
+	Audit          *audit.Logger
+	Publisher      *events.Publisher
+	Interval       time.Duration
+	BatchSize      int
+}
+
+func NewReassigner(sqlDB *sql.DB, crmSyncer *CRMSyncer, calendarSyncer *CalendarSyncer, auditLogger *audit.Logger, publisher *events.Publisher) *Reassigner {
+	interval := time.Minute
+	if v, err := strconv.Atoi(os.Getenv("REASSIGNMENT_INTERVAL_SECONDS")); err == nil && v > 0 {
+		interval = time.Duration(v) * time.Second
+	}
+
+	return &Reassigner{
+		DB:             sqlDB,
+		CRMSyncer:      crmSyncer,
+		CalendarSyncer: calendarSyncer,
+		Audit:          auditLogger,
+		Publisher:      publisher,
+		Interval:       interval,
+		BatchSize:      50,
+	}
+}
+
+// Run reassigns due appointments of every tenant every Interval until ctx is
+// done.
+func (r *Reassigner) Run(ctx context.Context) {
+	runEvery(ctx, "Reassigner", r.Interval, func(ctx context.Context) {
+		report, err := r.ReassignDue(ctx, "")
+		if err != nil {
+			log.Printf("Reassigner: %v", err)
+			return
+		}
+		if len(report.Reassigned) > 0 || len(report.Unplaced) > 0 {
+			log.Printf("Reassigner: reassigned %d appointments, %d could not be placed",
+				len(report.Reassigned), len(report.Unplaced))
+		}
+	})
+}
+
+// ReassignDue tries each flagged future appointment of tenantID, or of all
+// tenants when tenantID is empty, least recently tried first.
+func (r *Reassigner) ReassignDue(ctx context.Context, tenantID string) (*structs.ReassignmentReport, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+SELECT id FROM coach_appointments
+WHERE needs_reassignment AND status = 'scheduled' AND start_time > NOW()
+  AND ($1 = '' OR tenant_id = $1)
+ORDER BY reassignment_attempted_at NULLS FIRST, start_time
+LIMIT $2`, tenantID, r.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("query flagged appointments: %w", err)
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("Reassigner: scan appointment id: %v", err)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	report := &structs.ReassignmentReport{
+		Reassigned: []structs.ReassignedAppointment{},
+		Unplaced:   []structs.PendingReassignment{},
+	}
+	for _, id := range ids {
+		moved, unplaced, err := r.reassign(ctx, id)
+		switch {
+		case err != nil:
+			log.Printf("Reassigner: appointment %s: %v", id, err)
+		case moved != nil:
+			report.Reassigned = append(report.Reassigned, *moved)
+		case unplaced != nil:
+			report.Unplaced = append(report.Unplaced, *unplaced)
+		}
+	}
+	return report, nil
+}
+
+// reassign moves one appointment in its own transaction. It returns neither
+// result when the appointment no longer needs moving.
+func (r *Reassigner) reassign(ctx context.Context, appointmentID string) (*structs.ReassignedAppointment, *structs.PendingReassignment, error) {
+	tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var tenantID, flagReason string
+	var capacity int
+	appt := structs.AppointmentEvent{AppointmentID: appointmentID, Status: "scheduled"}
+	err = tx.QueryRowContext(ctx, `
+SELECT ca.tenant_id, ca.coach_id, ca.calendar_id, ca.start_time, ca.end_time, COALESCE(ca.reassignment_reason, ''),
+  COALESCE(cal.capacity, 1)
+FROM coach_appointments ca
+LEFT JOIN calendars cal ON cal.id = ca.calendar_id
+WHERE ca.id = $1 AND ca.needs_reassignment AND ca.status = 'scheduled'
+FOR UPDATE OF ca`, appointmentID).Scan(&tenantID, &appt.CoachID, &appt.CalendarID, &appt.StartTime, &appt.EndTime, &flagReason,
+		&capacity)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	selection, err := assignment.SelectCoach(ctx, tx, assignment.Request{
+		TenantID:       tenantID,
+		CalendarID:     appt.CalendarID,
+		StartTime:      appt.StartTime,
+		EndTime:        appt.EndTime,
+		ExcludeCoachID: appt.CoachID,
+		Capacity:       capacity,
+	})
+	if errors.Is(err, assignment.ErrNoCoach) {
+		now := time.Now().UTC()
+		_, err = tx.ExecContext(ctx, `UPDATE coach_appointments SET reassignment_attempted_at = $2,
+reassignment_error = $3 WHERE id = $1`, appointmentID, now, assignment.ErrNoCoach.Error())
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, nil, err
+		}
+		return nil, &structs.PendingReassignment{
+			AppointmentID: appointmentID,
+			CoachID:       appt.CoachID,
+			CalendarID:    appt.CalendarID,
+			StartTime:     appt.StartTime,
+			EndTime:       appt.EndTime,
+			Reason:        flagReason,
+			Error:         assignment.ErrNoCoach.Error(),
+			AttemptedAt:   &now,
+		}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A CRM "created" or calendar "block" that hasn't gone out yet is kept:
+	// it reads the coach from the row, so it already carries the new coach.
+	_, err = tx.ExecContext(ctx, `
+UPDATE coach_appointments SET coach_id = $2, previous_coach_id = $3, seat = $8,
+needs_reassignment = false, reassignment_reason = NULL, reassignment_error = NULL, reassignment_attempted_at = NOW(),
+crm_sync_event = CASE WHEN crm_sync_event = $4 AND crm_sync_status <> 'synced' THEN crm_sync_event ELSE $5 END,
+crm_sync_status = 'pending', crm_sync_attempts = 0, crm_last_error = NULL,
+calendar_sync_event = CASE WHEN calendar_sync_event = $6 AND calendar_sync_status <> 'synced' THEN calendar_sync_event ELSE $7 END,
+calendar_sync_status = 'pending', calendar_sync_attempts = 0, calendar_last_error = NULL,
+updated_at = NOW()
+WHERE id = $1`, appointmentID, selection.Coach.ID, appt.CoachID,
+		CRMEventCreated, CRMEventReassigned, CalendarEventBlock, CalendarEventMove, selection.Seat)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The previous coach's slots are left as they are; the next availability
+	// sync frees them once the calendar block has been released.
+	_, err = tx.ExecContext(ctx, `UPDATE coach_slots SET available = false, updated_at = NOW() WHERE coach_id = $1
+AND start_time >= $2 AND start_time < $3`, selection.Coach.ID, appt.StartTime, appt.EndTime)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	considered, _ := json.Marshal(selection.Considered)
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO distribution_log (
+			appointment_id, coaches_considered, selected_coach_id, selection_reason, distribution_score
+		) VALUES ($1, $2, $3, $4, $5)
+	`, appointmentID, considered, selection.Coach.ID,
+		fmt.Sprintf("Reassigned from %s (%s): %s", appt.CoachID, flagReason, selection.Reason), 1.0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	before := appt
+	appt.PreviousCoachID = appt.CoachID
+	appt.CoachID = selection.Coach.ID
+
+	if err := r.CRMSyncer.Sync(ctx, appointmentID); err != nil {
+		log.Printf("Reassigner: CRM update for %s queued for retry: %v", appointmentID, err)
+	}
+	if err := r.CalendarSyncer.Sync(ctx, appointmentID); err != nil {
+		log.Printf("Reassigner: calendar move for %s queued for retry: %v", appointmentID, err)
+	}
+
+	r.Audit.Record(ctx, audit.Entry{
+		TenantID:   tenantID,
+		Action:     structs.EventAppointmentReassigned,
+		EntityType: "appointment",
+		EntityID:   appointmentID,
+		Before:     before,
+		After:      appt,
+	})
+	r.Publisher.Publish(ctx, tenantID, structs.EventAppointmentReassigned, appt)
+
+	return &structs.ReassignedAppointment{
+		AppointmentID:   appointmentID,
+		PreviousCoachID: appt.PreviousCoachID,
+		CoachID:         appt.CoachID,
+	}, nil, nil
+}
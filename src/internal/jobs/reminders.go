@@ -0,0 +1,274 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/transistxr/coach-assignment-server/src/internal/db"
+	"github.com/transistxr/coach-assignment-server/src/internal/notify"
+)
+
+// ReminderScheduler sends appointment reminders Offsets before start_time.
+// Reminders are rows in appointment_reminders, written when an appointment
+// is booked or moved and cancelled with it, so scheduled reminders survive
+// restarts. Run delivers the due ones through Notifier, retrying failures up
+// to MaxAttempts. Content is rendered at send time, so a reassigned coach is
+// picked up without rescheduling.
+type ReminderScheduler struct {
+	DB          *sql.DB
+	Notifier    notify.Notifier
+	Enabled     bool
+	Offsets     []time.Duration
+	Interval    time.Duration
+	MaxAttempts int
+	BatchSize   int
+}
+
+func NewReminderScheduler(sqlDB *sql.DB, notifier notify.Notifier) *ReminderScheduler {
+	interval := time.Minute
+	if v, err := strconv.Atoi(os.Getenv("REMINDER_INTERVAL_SECONDS")); err == nil && v > 0 {
+		interval = time.Duration(v) * time.Second
+	}
+
+	maxAttempts := 5
+	if v, err := strconv.Atoi(os.Getenv("REMINDER_MAX_ATTEMPTS")); err == nil && v > 0 {
+		maxAttempts = v
+	}
+
+	offsets := []time.Duration{24 * time.Hour, time.Hour}
+	if v := os.Getenv("REMINDER_OFFSETS"); v != "" {
+		offsets = nil
+		for _, part := range strings.Split(v, ",") {
+			d, err := time.ParseDuration(strings.TrimSpace(part))
+			if err != nil || d < time.Minute {
+				log.Printf("ReminderScheduler: ignoring reminder offset %q", part)
+				continue
+			}
+			offsets = append(offsets, d)
+		}
+	}
+
+	enabled, _ := strconv.ParseBool(os.Getenv("ENABLE_APPOINTMENT_REMINDERS"))
+
+	return &ReminderScheduler{
+		DB:          sqlDB,
+		Notifier:    notifier,
+		Enabled:     enabled && len(offsets) > 0,
+		Offsets:     offsets,
+		Interval:    interval,
+		MaxAttempts: maxAttempts,
+		BatchSize:   50,
+	}
+}
+
+func (s *ReminderScheduler) offsetMinutes() []int64 {
+	minutes := make([]int64, 0, len(s.Offsets))
+	for _, d := range s.Offsets {
+		minutes = append(minutes, int64(d/time.Minute))
+	}
+	return minutes
+}
+
+// Schedule (re)creates the reminders of a scheduled appointment from its
+// current start_time. Reminders whose time has already passed are cancelled,
+// and a reminder that was sent for an earlier start_time is sent again.
+func (s *ReminderScheduler) Schedule(ctx context.Context, q db.Querier, appointmentID string) error {
+	if !s.Enabled {
+		return nil
+	}
+
+	_, err := q.ExecContext(ctx, `
+UPDATE appointment_reminders r SET status = 'cancelled', updated_at = NOW()
+FROM coach_appointments ca
+WHERE r.appointment_id = ca.id AND ca.id = $1 AND r.status IN ('pending', 'failed')
+  AND (ca.status <> 'scheduled' OR ca.start_time - make_interval(mins => r.offset_minutes) <= NOW()
+    OR NOT r.offset_minutes = ANY($2))`, appointmentID, pq.Array(s.offsetMinutes()))
+	if err != nil {
+		return err
+	}
+
+	_, err = q.ExecContext(ctx, `
+INSERT INTO appointment_reminders (tenant_id, appointment_id, offset_minutes, send_at)
+SELECT ca.tenant_id, ca.id, o, ca.start_time - make_interval(mins => o)
+FROM coach_appointments ca, unnest($2::int[]) AS o
+WHERE ca.id = $1 AND ca.status = 'scheduled' AND ca.start_time - make_interval(mins => o) > NOW()
+ON CONFLICT (appointment_id, offset_minutes) DO UPDATE
+SET send_at = EXCLUDED.send_at, status = 'pending', attempts = 0, last_attempt = NULL,
+  last_error = NULL, sent_at = NULL, updated_at = NOW()
+WHERE appointment_reminders.send_at <> EXCLUDED.send_at OR appointment_reminders.status = 'cancelled'`,
+		appointmentID, pq.Array(s.offsetMinutes()))
+	return err
+}
+
+// Cancel drops the appointment's unsent reminders.
+func (s *ReminderScheduler) Cancel(ctx context.Context, q db.Querier, appointmentID string) error {
+	_, err := q.ExecContext(ctx, `
+UPDATE appointment_reminders SET status = 'cancelled', updated_at = NOW()
+WHERE appointment_id = $1 AND status IN ('pending', 'failed')`, appointmentID)
+	return err
+}
+
+// Run sends due reminders every Interval until ctx is done. It does nothing
+// unless reminders are enabled.
+func (s *ReminderScheduler) Run(ctx context.Context) {
+	if !s.Enabled {
+		log.Printf("ReminderScheduler: disabled")
+		return
+	}
+	runEvery(ctx, "ReminderScheduler", s.Interval, s.sendDue)
+}
+
+// sendDue claims due reminders by bumping last_attempt under SKIP LOCKED, so
+// replicas don't send the same rows, and delivers them one by one.
+func (s *ReminderScheduler) sendDue(ctx context.Context) {
+	rows, err := s.DB.QueryContext(ctx, `
+UPDATE appointment_reminders SET last_attempt = NOW()
+WHERE id IN (
+  SELECT id FROM appointment_reminders
+  WHERE status IN ('pending', 'failed') AND send_at <= NOW()
+    AND attempts < $1
+    AND (last_attempt IS NULL OR last_attempt < NOW() - make_interval(secs => $2))
+  ORDER BY send_at
+  LIMIT $3
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id`, s.MaxAttempts, s.Interval.Seconds(), s.BatchSize)
+	if err != nil {
+		log.Printf("ReminderScheduler: failed to claim reminders: %v", err)
+		return
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("ReminderScheduler: scan reminder id: %v", err)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := s.send(ctx, id); err != nil {
+			log.Printf("ReminderScheduler: reminder %s not sent: %v", id, err)
+		}
+	}
+}
+
+// errReminderStale and errNoContactEmail cancel a reminder instead of
+// retrying it: no later attempt can succeed.
+var (
+	errReminderStale  = errors.New("appointment no longer upcoming")
+	errNoContactEmail = errors.New("appointment has no contact_email")
+)
+
+type reminderDetails struct {
+	sendAt       time.Time
+	status       string
+	startTime    time.Time
+	endTime      time.Time
+	timezone     string
+	contactEmail sql.NullString
+	contactName  sql.NullString
+	coachName    string
+	calendarName string
+}
+
+func (s *ReminderScheduler) send(ctx context.Context, reminderID string) error {
+	var d reminderDetails
+	err := s.DB.QueryRowContext(ctx, `
+SELECT r.send_at, ca.status, ca.start_time, ca.end_time, COALESCE(ca.timezone, 'UTC'),
+  ca.contact_email, ca.contact_name, c.name, cal.name
+FROM appointment_reminders r
+JOIN coach_appointments ca ON ca.id = r.appointment_id
+JOIN coaches c ON c.id = ca.coach_id
+JOIN calendars cal ON cal.id = ca.calendar_id
+WHERE r.id = $1 AND r.status IN ('pending', 'failed')`, reminderID).Scan(
+		&d.sendAt, &d.status, &d.startTime, &d.endTime, &d.timezone,
+		&d.contactEmail, &d.contactName, &d.coachName, &d.calendarName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if d.status != "scheduled" || !d.startTime.After(time.Now()) {
+		return s.record(ctx, reminderID, d.sendAt, errReminderStale)
+	}
+	if d.contactEmail.String == "" {
+		return s.record(ctx, reminderID, d.sendAt, errNoContactEmail)
+	}
+
+	err = s.Notifier.Send(ctx, reminderMessage(&d))
+	if recErr := s.record(ctx, reminderID, d.sendAt, err); recErr != nil {
+		log.Printf("ReminderScheduler: failed to record result of %s: %v", reminderID, recErr)
+	}
+	return err
+}
+
+// record stores the outcome of a delivery attempt. Matching on send_at keeps
+// a reminder rescheduled in the meantime pending.
+func (s *ReminderScheduler) record(ctx context.Context, reminderID string, sendAt time.Time, sendErr error) error {
+	var err error
+	switch {
+	case sendErr == nil:
+		_, err = s.DB.ExecContext(ctx, `
+UPDATE appointment_reminders SET status = 'sent', attempts = attempts + 1, sent_at = NOW(), last_error = NULL, updated_at = NOW()
+WHERE id = $1 AND send_at = $2`, reminderID, sendAt)
+	case errors.Is(sendErr, errReminderStale), errors.Is(sendErr, errNoContactEmail):
+		_, err = s.DB.ExecContext(ctx, `
+UPDATE appointment_reminders SET status = 'cancelled', last_error = $3, updated_at = NOW()
+WHERE id = $1 AND send_at = $2`, reminderID, sendAt, sendErr.Error())
+	default:
+		_, err = s.DB.ExecContext(ctx, `
+UPDATE appointment_reminders SET status = 'failed', attempts = attempts + 1, last_error = $3, updated_at = NOW()
+WHERE id = $1 AND send_at = $2`, reminderID, sendAt, sendErr.Error())
+	}
+	return err
+}
+
+func reminderMessage(d *reminderDetails) notify.Message {
+	loc, err := time.LoadLocation(d.timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	start := d.startTime.In(loc)
+
+	var body strings.Builder
+	if d.contactName.String != "" {
+		fmt.Fprintf(&body, "Hi %s,\n\n", d.contactName.String)
+	}
+	fmt.Fprintf(&body, "This is a reminder of your %s appointment with %s.\n\n", d.calendarName, d.coachName)
+	fmt.Fprintf(&body, "When: %s - %s (%s)\n", start.Format("Monday, January 2, 2006 15:04"),
+		d.endTime.In(loc).Format("15:04"), loc.String())
+	fmt.Fprintf(&body, "Starts in: %s\n", humanizeDuration(time.Until(d.startTime)))
+
+	return notify.Message{
+		To:      d.contactEmail.String,
+		ToName:  d.contactName.String,
+		Subject: fmt.Sprintf("Reminder: %s on %s", d.calendarName, start.Format("Jan 2 at 15:04")),
+		Body:    body.String(),
+	}
+}
+
+// humanizeDuration rounds d to whole hours, or minutes below two hours.
+func humanizeDuration(d time.Duration) string {
+	if d >= 2*time.Hour {
+		return fmt.Sprintf("%d hours", int(d.Round(time.Hour)/time.Hour))
+	}
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	if minutes == 1 {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", minutes)
+}
@@ -0,0 +1,23 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// runEvery calls fn every interval until ctx is done.
+func runEvery(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context)) {
+	log.Printf("%s: running every %v", name, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn(ctx)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/audit"
+	"github.com/transistxr/coach-assignment-server/src/internal/scoring"
+)
+
+// ScoreRecomputer periodically recomputes coach scores from their appointment
+// outcomes over the last Window and records every change in
+// coach_score_history. Coaches with a locked (manually set) score, or fewer
+// than MinAppointments outcomes in the window, keep their current score.
+type ScoreRecomputer struct {
+	DB              *sql.DB
+	Audit           *audit.Logger
+	Weights         scoring.Weights
+	Window          time.Duration
+	MinAppointments int
+	Interval        time.Duration
+}
+
+func NewScoreRecomputer(sqlDB *sql.DB, auditLogger *audit.Logger) *ScoreRecomputer {
+	interval := time.Hour
+	if v, err := strconv.Atoi(os.Getenv("SCORE_RECOMPUTE_INTERVAL_SECONDS")); err == nil && v > 0 {
+		interval = time.Duration(v) * time.Second
+	}
+
+	window := 90 * 24 * time.Hour
+	if v, err := strconv.Atoi(os.Getenv("SCORE_WINDOW_DAYS")); err == nil && v > 0 {
+		window = time.Duration(v) * 24 * time.Hour
+	}
+
+	minAppointments := 5
+	if v, err := strconv.Atoi(os.Getenv("SCORE_MIN_APPOINTMENTS")); err == nil && v > 0 {
+		minAppointments = v
+	}
+
+	return &ScoreRecomputer{
+		DB:              sqlDB,
+		Audit:           auditLogger,
+		Weights:         scoring.WeightsFromEnv(),
+		Window:          window,
+		MinAppointments: minAppointments,
+		Interval:        interval,
+	}
+}
+
+// Run recomputes scores every Interval until ctx is done.
+func (s *ScoreRecomputer) Run(ctx context.Context) {
+	runEvery(ctx, "ScoreRecomputer", s.Interval, func(ctx context.Context) {
+		if _, err := s.Recompute(ctx); err != nil {
+			log.Printf("ScoreRecomputer: %v", err)
+		}
+	})
+}
+
+// scoreDetails is stored with each recomputed score in coach_score_history.
+type scoreDetails struct {
+	Outcomes   scoring.Outcomes `json:"outcomes"`
+	Weights    scoring.Weights  `json:"weights"`
+	WindowDays int              `json:"window_days"`
+}
+
+type coachOutcomes struct {
+	coachID  string
+	tenantID string
+	score    float64
+	outcomes scoring.Outcomes
+}
+
+// Recompute updates every eligible coach and returns how many scores changed.
+func (s *ScoreRecomputer) Recompute(ctx context.Context) (int, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+SELECT c.id, c.tenant_id, COALESCE(c.score, 0),
+  COUNT(*) FILTER (WHERE ca.status = 'completed'),
+  COUNT(*) FILTER (WHERE ca.status = 'no_show'),
+  COUNT(*) FILTER (WHERE ca.status = 'cancelled'),
+  AVG(f.rating)
+FROM coaches c
+JOIN coach_appointments ca ON ca.coach_id = c.id
+LEFT JOIN appointment_feedback f ON f.appointment_id = ca.id AND f.coach_id = c.id
+WHERE c.active AND NOT c.score_locked
+  AND ca.start_time >= NOW() - make_interval(secs => $1) AND ca.start_time < NOW()
+GROUP BY c.id, c.tenant_id, c.score`, s.Window.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("query outcomes: %w", err)
+	}
+
+	var coaches []coachOutcomes
+	for rows.Next() {
+		var c coachOutcomes
+		var avgRating sql.NullFloat64
+		if err := rows.Scan(&c.coachID, &c.tenantID, &c.score,
+			&c.outcomes.Completed, &c.outcomes.NoShow, &c.outcomes.Cancelled, &avgRating); err != nil {
+			log.Printf("ScoreRecomputer: scan outcomes: %v", err)
+			continue
+		}
+		if avgRating.Valid {
+			c.outcomes.AvgRating = &avgRating.Float64
+		}
+		coaches = append(coaches, c)
+	}
+	rows.Close()
+
+	changed := 0
+	for _, c := range coaches {
+		if c.outcomes.Total() < s.MinAppointments {
+			continue
+		}
+		score, ok := scoring.Compute(c.outcomes, s.Weights)
+		if !ok || math.Abs(score-c.score) < 0.001 {
+			continue
+		}
+		if err := s.update(ctx, c, score); err != nil {
+			log.Printf("ScoreRecomputer: coach %s: %v", c.coachID, err)
+			continue
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+func (s *ScoreRecomputer) update(ctx context.Context, c coachOutcomes, score float64) error {
+	details, err := json.Marshal(scoreDetails{
+		Outcomes:   c.outcomes,
+		Weights:    s.Weights,
+		WindowDays: int(s.Window / (24 * time.Hour)),
+	})
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Re-check the lock: a manual update may have landed since the query.
+	res, err := tx.ExecContext(ctx, `UPDATE coaches SET score = $2, updated_at = NOW()
+WHERE id = $1 AND NOT score_locked`, c.coachID, score)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO coach_score_history (tenant_id, coach_id, score, previous_score, source, details)
+VALUES ($1, $2, $3, $4, 'recompute', $5)`, c.tenantID, c.coachID, score, c.score, details)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.Audit.Record(ctx, audit.Entry{
+		TenantID:   c.tenantID,
+		Action:     audit.ActionCoachScoreChanged,
+		EntityType: "coach",
+		EntityID:   c.coachID,
+		Before:     map[string]float64{"score": c.score},
+		After:      map[string]any{"score": score, "source": "recompute"},
+	})
+	return nil
+}
@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Message is a plain-text notification to one recipient.
+type Message struct {
+	To      string
+	ToName  string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages. Implementations must be safe for concurrent
+// use; an error means the message may not have been delivered and can be
+// retried.
+type Notifier interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// NewFromEnv returns an SMTPNotifier when SMTP_HOST is set (SMTP_PORT,
+// SMTP_USERNAME, SMTP_PASSWORD, SMTP_FROM and SMTP_TIMEOUT_MS configure it)
+// and a LogNotifier otherwise.
+func NewFromEnv() Notifier {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		log.Printf("Notify: SMTP_HOST not set, notifications are only logged")
+		return LogNotifier{}
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "25"
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+	timeout := 10 * time.Second
+	if v, err := strconv.Atoi(os.Getenv("SMTP_TIMEOUT_MS")); err == nil && v > 0 {
+		timeout = time.Duration(v) * time.Millisecond
+	}
+
+	return &SMTPNotifier{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+		Timeout:  timeout,
+	}
+}
+
+// LogNotifier writes messages to the log instead of delivering them.
+type LogNotifier struct{}
+
+func (LogNotifier) Send(ctx context.Context, m Message) error {
+	log.Printf("Notify: to %s: %s", m.To, m.Subject)
+	return nil
+}
@@ -0,0 +1,222 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBuildMessage(t *testing.T) {
+	from := &mail.Address{Name: "Coach Scheduling", Address: "no-reply@example.com"}
+	to := &mail.Address{Name: "Jane Doe", Address: "jane@example.com"}
+
+	msg := string(buildMessage(from, to, Message{
+		Subject: "Reminder\r\nBcc: attacker@example.com",
+		Body:    "Line one\nLine two\r\nLine three",
+	}))
+
+	headers, body, ok := strings.Cut(msg, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("no blank line between headers and body:\n%q", msg)
+	}
+	for _, want := range []string{
+		`From: "Coach Scheduling" <no-reply@example.com>`,
+		`To: "Jane Doe" <jane@example.com>`,
+		"Content-Type: text/plain; charset=UTF-8",
+	} {
+		if !strings.Contains(headers, want+"\r\n") && !strings.HasSuffix(headers, want) {
+			t.Errorf("headers missing %q:\n%s", want, headers)
+		}
+	}
+	for _, line := range strings.Split(headers, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Errorf("subject injected a header: %q", line)
+		}
+		if strings.HasPrefix(line, "Subject:") && !strings.HasPrefix(line, "Subject: =?utf-8?q?") {
+			t.Errorf("subject not encoded: %q", line)
+		}
+	}
+	if body != "Line one\r\nLine two\r\nLine three\r\n" {
+		t.Errorf("body = %q, want CRLF line endings", body)
+	}
+	if strings.Contains(strings.ReplaceAll(msg, "\r\n", ""), "\n") {
+		t.Errorf("bare LF in message: %q", msg)
+	}
+}
+
+// fakeSMTP accepts one connection and records the session. Recipients in
+// reject get a permanent failure.
+type fakeSMTP struct {
+	addr   string
+	reject map[string]bool
+
+	mu       sync.Mutex
+	commands []string
+	data     string
+	done     chan struct{}
+}
+
+func newFakeSMTP(t *testing.T, reject ...string) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeSMTP{addr: ln.Addr().String(), reject: map[string]bool{}, done: make(chan struct{})}
+	for _, r := range reject {
+		s.reject[r] = true
+	}
+	go func() {
+		defer close(s.done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.serve(conn)
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+
+		verb := strings.ToUpper(strings.Fields(line + " ")[0])
+		switch verb {
+		case "EHLO":
+			reply("250-fake")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			reply("250 OK")
+		case "RCPT":
+			if s.reject[strings.TrimSuffix(strings.TrimPrefix(line, "RCPT TO:<"), ">")] {
+				reply("550 5.1.1 No such user")
+			} else {
+				reply("250 OK")
+			}
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTP) session(t *testing.T) ([]string, string) {
+	t.Helper()
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP session did not finish")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands, s.data
+}
+
+func notifierFor(s *fakeSMTP) *SMTPNotifier {
+	host, port, _ := net.SplitHostPort(s.addr)
+	return &SMTPNotifier{Host: host, Port: port, From: "Coach Scheduling <no-reply@example.com>", Timeout: 5 * time.Second}
+}
+
+func TestSMTPNotifierSend(t *testing.T) {
+	server := newFakeSMTP(t)
+	n := notifierFor(server)
+	n.Username = "mailer"
+	n.Password = "secret"
+
+	err := n.Send(context.Background(), Message{To: "jane@example.com", ToName: "Jane Doe", Subject: "Reminder", Body: "See you soon."})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	commands, data := server.session(t)
+	auth := "AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00mailer\x00secret"))
+	want := []string{auth, "MAIL FROM:<no-reply@example.com>", "RCPT TO:<jane@example.com>", "DATA", "QUIT"}
+	if len(commands) != len(want)+1 {
+		t.Fatalf("commands = %q, want EHLO then %q", commands, want)
+	}
+	for i, c := range want {
+		if !strings.HasPrefix(commands[i+1], c) {
+			t.Errorf("command %d = %q, want %q", i+1, commands[i+1], c)
+		}
+	}
+	if !strings.Contains(data, "To: \"Jane Doe\" <jane@example.com>\r\n") || !strings.HasSuffix(data, "\r\nSee you soon.\r\n") {
+		t.Errorf("unexpected message:\n%s", data)
+	}
+}
+
+func TestSMTPNotifierSendWithoutCredentials(t *testing.T) {
+	server := newFakeSMTP(t)
+
+	if err := notifierFor(server).Send(context.Background(), Message{To: "jane@example.com", Subject: "Reminder", Body: "Hi"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	commands, _ := server.session(t)
+	for _, c := range commands {
+		if strings.HasPrefix(c, "AUTH") {
+			t.Errorf("authenticated without credentials: %q", c)
+		}
+	}
+}
+
+func TestSMTPNotifierSendRejectedRecipient(t *testing.T) {
+	server := newFakeSMTP(t, "nobody@example.com")
+
+	err := notifierFor(server).Send(context.Background(), Message{To: "nobody@example.com", Subject: "Reminder", Body: "Hi"})
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Fatalf("Send err = %v, want the server's 550", err)
+	}
+}
+
+func TestSMTPNotifierSendInvalidAddresses(t *testing.T) {
+	n := &SMTPNotifier{Host: "127.0.0.1", Port: "1", From: "no-reply@example.com", Timeout: time.Second}
+	if err := n.Send(context.Background(), Message{To: "not an address"}); err == nil {
+		t.Error("Send accepted an invalid recipient")
+	}
+
+	n.From = "not an address"
+	if err := n.Send(context.Background(), Message{To: "jane@example.com"}); err == nil {
+		t.Error("Send accepted an invalid sender")
+	}
+}
@@ -0,0 +1,216 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/transistxr/coach-assignment-server/src/internal/auth"
+	"github.com/transistxr/coach-assignment-server/src/internal/db"
+	"github.com/transistxr/coach-assignment-server/src/internal/structs"
+)
+
+// Limiter enforces fixed-window request limits per client IP and per API
+// key. Counters live in Redis so every replica shares them; the per-key limit
+// comes from the key's rate_limit, falling back to DefaultLimit.
+type Limiter struct {
+	rdb          *db.RedisClient
+	Window       time.Duration
+	DefaultLimit int
+	IPLimit      int
+}
+
+// NewLimiterFromEnv reads RATE_LIMIT_WINDOW_MS, RATE_LIMIT_MAX_REQUESTS and
+// RATE_LIMIT_IP_MAX_REQUESTS.
+func NewLimiterFromEnv(rdb *db.RedisClient) *Limiter {
+	window := time.Minute
+	if v, err := strconv.Atoi(os.Getenv("RATE_LIMIT_WINDOW_MS")); err == nil && v > 0 {
+		window = time.Duration(v) * time.Millisecond
+	}
+
+	limit := 100
+	if v, err := strconv.Atoi(os.Getenv("RATE_LIMIT_MAX_REQUESTS")); err == nil && v > 0 {
+		limit = v
+	}
+
+	ipLimit := 300
+	if v, err := strconv.Atoi(os.Getenv("RATE_LIMIT_IP_MAX_REQUESTS")); err == nil && v > 0 {
+		ipLimit = v
+	}
+
+	return &Limiter{rdb: rdb, Window: window, DefaultLimit: limit, IPLimit: ipLimit}
+}
+
+// Result describes the caller's window after counting a request.
+type Result struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	Allowed   bool
+}
+
+// window returns the Redis key counting bucket in the current window and the
+// time the window ends.
+func (l *Limiter) window(bucket string) (string, time.Time) {
+	windowStart := time.Now().Truncate(l.Window)
+	return fmt.Sprintf("ratelimit:%s:%d", bucket, windowStart.Unix()), windowStart.Add(l.Window)
+}
+
+// Take counts one request for bucket and reports whether it fits the limit.
+func (l *Limiter) Take(ctx context.Context, bucket string, limit int) (*Result, error) {
+	key, reset := l.window(bucket)
+
+	var incr *redis.IntCmd
+	_, err := l.rdb.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.ExpireAt(ctx, key, reset.Add(time.Second))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	count := int(incr.Val())
+	return &Result{
+		Limit:     limit,
+		Remaining: max(limit-count, 0),
+		Reset:     reset,
+		Allowed:   count <= limit,
+	}, nil
+}
+
+// ipBucket returns the bucket counting requests from r's client IP.
+func ipBucket(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return "ip:" + ip
+}
+
+// IPMiddleware limits requests by client IP. It is meant for the routes that
+// take no API key, the calendar webhook and feedback links.
+func (l *Limiter) IPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.serve(w, r, next, ipBucket(r), l.IPLimit)
+	})
+}
+
+// FailedAuthMiddleware wraps authenticate so that the client IP's limit
+// applies only to the requests it turns away: once the IP has used up its
+// limit, their answer becomes a 429. Requests with a valid key pass straight
+// to next and are left to the per-key Middleware.
+func (l *Limiter) FailedAuthMiddleware(authenticate func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authenticated := false
+			failure := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+			authenticate(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				authenticated = true
+				next.ServeHTTP(w, r)
+			})).ServeHTTP(failure, r)
+			if authenticated {
+				return
+			}
+
+			res, err := l.Take(r.Context(), ipBucket(r), l.IPLimit)
+			if err != nil {
+				log.Printf("Rate limiter unavailable, failed authentication not counted: %v", err)
+			} else if !res.Allowed {
+				reject(w, res)
+				return
+			}
+			failure.writeTo(w)
+		})
+	}
+}
+
+// bufferedResponse holds the answer to a request that failed authentication
+// until the IP limit decides whether it is sent.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
+
+func (b *bufferedResponse) writeTo(w http.ResponseWriter) {
+	for k, v := range b.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
+
+// Middleware limits requests by the authenticated principal and sets the
+// X-RateLimit-* headers. It must run after auth.Authenticate. Redis failures
+// let the request through rather than taking the API down with them.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.PrincipalFrom(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limit := principal.RateLimit
+		if limit <= 0 {
+			limit = l.DefaultLimit
+		}
+
+		bucket := principal.KeyID
+		if bucket == "" {
+			bucket = principal.KeyHash
+		}
+
+		l.serve(w, r, next, bucket, limit)
+	})
+}
+
+// serve counts the request against bucket and either passes it on or answers
+// 429.
+func (l *Limiter) serve(w http.ResponseWriter, r *http.Request, next http.Handler, bucket string, limit int) {
+	res, err := l.Take(r.Context(), bucket, limit)
+	if err != nil {
+		log.Printf("Rate limiter unavailable, allowing request: %v", err)
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(res.Reset.Unix(), 10))
+
+	if !res.Allowed {
+		reject(w, res)
+		return
+	}
+	next.ServeHTTP(w, r)
+}
+
+// reject answers 429 with a Retry-After header for the end of res's window.
+func reject(w http.ResponseWriter, res *Result) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(res.Reset.Unix(), 10))
+
+	retryAfter := max(int(time.Until(res.Reset).Seconds()+0.999), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(structs.BaseResponse{
+		Error:   "RATE_LIMIT_EXCEEDED",
+		Message: fmt.Sprintf("Rate limit of %d requests exceeded, retry in %d seconds", res.Limit, retryAfter),
+	})
+}
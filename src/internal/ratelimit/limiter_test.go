@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/transistxr/coach-assignment-server/src/internal/db/redistest"
+)
+
+func TestIPMiddlewareLimitsUnauthenticatedRequests(t *testing.T) {
+	rdb, _ := redistest.New(t)
+	limiter := &Limiter{rdb: rdb, Window: time.Minute, DefaultLimit: 100, IPLimit: 2}
+
+	handler := limiter.IPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+
+	send := func(remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/webhooks/calendar", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := send("203.0.113.7:4000"); code != http.StatusUnauthorized {
+			t.Fatalf("request %d: got %d, want it passed through", i+1, code)
+		}
+	}
+	if code := send("203.0.113.7:4001"); code != http.StatusTooManyRequests {
+		t.Errorf("over the limit from another port: got %d, want 429", code)
+	}
+	if code := send("198.51.100.2:4000"); code != http.StatusUnauthorized {
+		t.Errorf("another IP: got %d, want it passed through", code)
+	}
+}
+
+func TestFailedAuthMiddlewareOnlyCountsRejectedKeys(t *testing.T) {
+	rdb, _ := redistest.New(t)
+	limiter := &Limiter{rdb: rdb, Window: time.Minute, DefaultLimit: 100, IPLimit: 2}
+
+	// A stand-in for auth.KeyStore.Authenticate accepting only "good".
+	authenticate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-API-Key") != "good" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	handler := limiter.FailedAuthMiddleware(authenticate)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/availability", nil)
+		req.RemoteAddr = "203.0.113.7:4000"
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Valid keys never use up the IP's budget.
+	for i := 0; i < 5; i++ {
+		if code := send("good"); code != http.StatusOK {
+			t.Fatalf("valid key %d: got %d, want 200", i+1, code)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if code := send("bad"); code != http.StatusUnauthorized {
+			t.Fatalf("bad key %d: got %d, want 401", i+1, code)
+		}
+	}
+	if code := send("bad"); code != http.StatusTooManyRequests {
+		t.Errorf("bad key over the limit: got %d, want 429", code)
+	}
+	if code := send("good"); code != http.StatusOK {
+		t.Errorf("valid key after the IP limit is used up: got %d, want 200", code)
+	}
+}
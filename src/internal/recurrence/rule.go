@@ -0,0 +1,113 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxOccurrences bounds a series however far its UNTIL lies.
+const MaxOccurrences = 52
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// Rule is the subset of RFC 5545 RRULE supported for appointment series:
+// FREQ=WEEKLY with INTERVAL 1 (weekly) or 2 (biweekly), ended by either COUNT
+// or UNTIL, e.g. "FREQ=WEEKLY;INTERVAL=2;COUNT=6".
+type Rule struct {
+	Interval int
+	Count    int
+	// Until is inclusive; zero when Count is set.
+	Until time.Time
+}
+
+// Parse reads rule, with or without the "RRULE:" prefix. A date-only UNTIL
+// (YYYYMMDD) covers that whole day in loc; UNTIL=YYYYMMDDTHHMMSSZ is UTC.
+func Parse(rule string, loc *time.Location) (*Rule, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if rule == "" {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidRule)
+	}
+
+	r := &Rule{Interval: 1}
+	var freq string
+	for _, part := range strings.Split(rule, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q is not KEY=VALUE", ErrInvalidRule, part)
+		}
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			freq = strings.ToUpper(value)
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || (n != 1 && n != 2) {
+				return nil, fmt.Errorf("%w: INTERVAL must be 1 or 2", ErrInvalidRule)
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > MaxOccurrences {
+				return nil, fmt.Errorf("%w: COUNT must be between 1 and %d", ErrInvalidRule, MaxOccurrences)
+			}
+			r.Count = n
+		case "UNTIL":
+			until, err := parseUntil(value, loc)
+			if err != nil {
+				return nil, fmt.Errorf("%w: UNTIL must be YYYYMMDD or YYYYMMDDTHHMMSSZ", ErrInvalidRule)
+			}
+			r.Until = until
+		default:
+			return nil, fmt.Errorf("%w: %s is not supported", ErrInvalidRule, key)
+		}
+	}
+
+	if freq != "WEEKLY" {
+		return nil, fmt.Errorf("%w: only FREQ=WEEKLY is supported", ErrInvalidRule)
+	}
+	if (r.Count == 0) == r.Until.IsZero() {
+		return nil, fmt.Errorf("%w: give exactly one of COUNT and UNTIL", ErrInvalidRule)
+	}
+	return r, nil
+}
+
+func parseUntil(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation("20060102", value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Second), nil
+}
+
+// String renders the rule in RRULE form.
+func (r *Rule) String() string {
+	s := "FREQ=WEEKLY;INTERVAL=" + strconv.Itoa(r.Interval)
+	if r.Count > 0 {
+		return s + ";COUNT=" + strconv.Itoa(r.Count)
+	}
+	return s + ";UNTIL=" + r.Until.UTC().Format("20060102T150405Z")
+}
+
+// Occurrences returns the start times of the series beginning at start,
+// first included. Weeks are counted in loc so the local time of day holds
+// across daylight saving changes.
+func (r *Rule) Occurrences(start time.Time, loc *time.Location) []time.Time {
+	local := start.In(loc)
+	var out []time.Time
+	for i := 0; i < MaxOccurrences; i++ {
+		t := local.AddDate(0, 0, 7*r.Interval*i)
+		if r.Count > 0 && i >= r.Count {
+			break
+		}
+		if !r.Until.IsZero() && t.After(r.Until) {
+			break
+		}
+		out = append(out, t.UTC())
+	}
+	return out
+}
@@ -0,0 +1,155 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParse(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		rule string
+		want *Rule
+	}{
+		{"count", "FREQ=WEEKLY;COUNT=4", &Rule{Interval: 1, Count: 4}},
+		{"biweekly with prefix", "RRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=6", &Rule{Interval: 2, Count: 6}},
+		{"lower case keys", "freq=weekly;count=52", &Rule{Interval: 1, Count: 52}},
+		{"utc until", "FREQ=WEEKLY;UNTIL=20240318T150000Z", &Rule{Interval: 1, Until: time.Date(2024, 3, 18, 15, 0, 0, 0, time.UTC)}},
+		{"date-only until covers the local day", "FREQ=WEEKLY;UNTIL=20240318", &Rule{Interval: 1, Until: time.Date(2024, 3, 18, 23, 59, 59, 0, newYork)}},
+		{"empty", "", nil},
+		{"count and until", "FREQ=WEEKLY;COUNT=4;UNTIL=20240318", nil},
+		{"neither count nor until", "FREQ=WEEKLY;INTERVAL=2", nil},
+		{"count over the cap", "FREQ=WEEKLY;COUNT=53", nil},
+		{"zero count", "FREQ=WEEKLY;COUNT=0", nil},
+		{"interval 3", "FREQ=WEEKLY;INTERVAL=3;COUNT=2", nil},
+		{"daily", "FREQ=DAILY;COUNT=2", nil},
+		{"missing freq", "COUNT=2", nil},
+		{"unsupported part", "FREQ=WEEKLY;COUNT=2;BYDAY=MO", nil},
+		{"not key=value", "FREQ=WEEKLY;COUNT", nil},
+		{"bad until", "FREQ=WEEKLY;UNTIL=2024-03-18", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse(tc.rule, newYork)
+			if tc.want == nil {
+				if !errors.Is(err, ErrInvalidRule) {
+					t.Fatalf("Parse(%q) = (%+v, %v), want ErrInvalidRule", tc.rule, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tc.rule, err)
+			}
+			if got.Interval != tc.want.Interval || got.Count != tc.want.Count || !got.Until.Equal(tc.want.Until) {
+				t.Fatalf("Parse(%q) = %+v, want %+v", tc.rule, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		rule  string
+		start time.Time
+		want  []time.Time
+	}{
+		{
+			// DST starts on 10 March: 9:00 local is 14:00Z before and 13:00Z after.
+			name:  "weekly keeps local time across DST",
+			rule:  "FREQ=WEEKLY;COUNT=3",
+			start: utc(time.March, 4, 14),
+			want:  []time.Time{utc(time.March, 4, 14), utc(time.March, 11, 13), utc(time.March, 18, 13)},
+		},
+		{
+			name:  "biweekly",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;COUNT=3",
+			start: utc(time.March, 4, 14),
+			want:  []time.Time{utc(time.March, 4, 14), utc(time.March, 18, 13), utc(time.April, 1, 13)},
+		},
+		{
+			// 21:00 local on 18 March is already 19 March in UTC.
+			name:  "date-only until includes the local day",
+			rule:  "FREQ=WEEKLY;UNTIL=20240318",
+			start: utc(time.March, 5, 2),
+			want:  []time.Time{utc(time.March, 5, 2), utc(time.March, 12, 1), utc(time.March, 19, 1)},
+		},
+		{
+			name:  "utc until is exact",
+			rule:  "FREQ=WEEKLY;UNTIL=20240319T005959Z",
+			start: utc(time.March, 5, 2),
+			want:  []time.Time{utc(time.March, 5, 2), utc(time.March, 12, 1)},
+		},
+		{
+			name:  "until on an occurrence includes it",
+			rule:  "FREQ=WEEKLY;UNTIL=20240311T130000Z",
+			start: utc(time.March, 4, 14),
+			want:  []time.Time{utc(time.March, 4, 14), utc(time.March, 11, 13)},
+		},
+		{
+			name:  "until before start",
+			rule:  "FREQ=WEEKLY;UNTIL=20240301",
+			start: utc(time.March, 4, 14),
+			want:  nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := Parse(tc.rule, newYork)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tc.rule, err)
+			}
+			got := r.Occurrences(tc.start, newYork)
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d occurrences %v, want %v", len(got), got, tc.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tc.want[i]) || got[i].Location() != time.UTC {
+					t.Errorf("occurrence %d = %v, want %v", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestOccurrencesCap(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for _, rule := range []string{"FREQ=WEEKLY;UNTIL=20300101", "FREQ=WEEKLY;INTERVAL=2;UNTIL=20300101", "FREQ=WEEKLY;COUNT=52"} {
+		r, err := Parse(rule, time.UTC)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", rule, err)
+		}
+		got := r.Occurrences(start, time.UTC)
+		if len(got) != MaxOccurrences {
+			t.Errorf("%q: got %d occurrences, want %d", rule, len(got), MaxOccurrences)
+		}
+		if want := start.AddDate(0, 0, 7*r.Interval*(MaxOccurrences-1)); !got[len(got)-1].Equal(want) {
+			t.Errorf("%q: last occurrence %v, want %v", rule, got[len(got)-1], want)
+		}
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	for _, rule := range []string{"FREQ=WEEKLY;INTERVAL=1;COUNT=4", "FREQ=WEEKLY;INTERVAL=2;UNTIL=20240318T150000Z"} {
+		r, err := Parse(rule, time.UTC)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", rule, err)
+		}
+		if got := r.String(); got != rule {
+			t.Errorf("String() = %q, want %q", got, rule)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Policy describes how an outbound HTTP call is retried: exponential backoff
+// from BaseDelay, capped at MaxDelay, plus up to Jitter (a fraction of the
+// delay) of random spread. A Retry-After header on a retryable response
+// replaces the computed delay, still capped at MaxDelay.
+type Policy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64
+	Retryable   func(resp *http.Response, err error) bool
+}
+
+// DefaultRetryable retries transport errors, 5xx and 429 responses. Context
+// cancellation is never retried.
+func DefaultRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// ServerErrorsOnly retries transport errors and 5xx responses, for endpoints
+// where a 429 is an answer rather than a transient failure.
+func ServerErrorsOnly(resp *http.Response, err error) bool {
+	if err != nil {
+		return DefaultRetryable(resp, err)
+	}
+	return resp.StatusCode >= 500
+}
+
+// PolicyFromEnv builds the default policy from WEBHOOK_RETRY_ATTEMPTS,
+// WEBHOOK_RETRY_DELAY_MS, RETRY_MAX_DELAY_MS and RETRY_JITTER. It is meant to
+// be called once at startup; missing or invalid values fall back to defaults.
+func PolicyFromEnv() Policy {
+	p := Policy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+		MaxDelay:    30 * time.Second,
+		Jitter:      0.5,
+		Retryable:   DefaultRetryable,
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("WEBHOOK_RETRY_ATTEMPTS")); err == nil && v > 0 {
+		p.MaxAttempts = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("WEBHOOK_RETRY_DELAY_MS")); err == nil && v >= 0 {
+		p.BaseDelay = time.Duration(v) * time.Millisecond
+	}
+	if v, err := strconv.Atoi(os.Getenv("RETRY_MAX_DELAY_MS")); err == nil && v > 0 {
+		p.MaxDelay = time.Duration(v) * time.Millisecond
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("RETRY_JITTER"), 64); err == nil && v >= 0 && v <= 1 {
+		p.Jitter = v
+	}
+	return p
+}
+
+// WithRetryable returns a copy of p using the given predicate.
+func (p Policy) WithRetryable(retryable func(resp *http.Response, err error) bool) Policy {
+	p.Retryable = retryable
+	return p
+}
+
+type singleAttemptKey struct{}
+
+// SingleAttempt returns a context under which Do makes one attempt whatever
+// the policy, for callers on a request path whose failures are retried in the
+// background.
+func SingleAttempt(ctx context.Context) context.Context {
+	return context.WithValue(ctx, singleAttemptKey{}, true)
+}
+
+// Do calls send until it returns a non-retryable outcome, attempts run out or
+// ctx is done. send must build a fresh request on every call. The response of
+// the final attempt is returned with its body open; bodies of retried
+// responses are drained and closed here.
+func (p Policy) Do(ctx context.Context, send func(ctx context.Context) (*http.Response, error)) (*http.Response, error) {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+	attempts := max(p.MaxAttempts, 1)
+	if single, _ := ctx.Value(singleAttemptKey{}).(bool); single {
+		attempts = 1
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := send(ctx)
+
+		if attempt == attempts-1 || !retryable(resp, err) {
+			return resp, err
+		}
+
+		delay := p.backoff(attempt)
+		if err != nil {
+			log.Printf("Attempt No. %d failed: %v, retrying in %v", attempt, err, delay)
+		} else {
+			if ra, ok := retryAfter(resp); ok {
+				delay = min(ra, p.MaxDelay)
+			}
+			log.Printf("Attempt No. %d got status %d, retrying in %v", attempt, resp.StatusCode, delay)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (p Policy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	if delay > 0 {
+		// A shift past the top bit would wrap around; such a delay is over
+		// any cap.
+		if attempt >= 63 || delay > math.MaxInt64>>attempt {
+			delay = math.MaxInt64
+		} else {
+			delay <<= attempt
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 && delay > 0 {
+		delay += time.Duration(rand.Int63n(int64(float64(delay)*p.Jitter) + 1))
+	}
+	return delay
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an
+// HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,166 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSingleAttempt(t *testing.T) {
+	p := Policy{MaxAttempts: 5, Retryable: DefaultRetryable}
+	failing := errors.New("connection refused")
+
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+		want int
+	}{
+		{"policy", context.Background(), 5},
+		{"single attempt", SingleAttempt(context.Background()), 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			_, err := p.Do(tc.ctx, func(ctx context.Context) (*http.Response, error) {
+				calls++
+				return nil, failing
+			})
+			if !errors.Is(err, failing) {
+				t.Fatalf("err = %v, want %v", err, failing)
+			}
+			if calls != tc.want {
+				t.Fatalf("calls = %d, want %d", calls, tc.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		policy  Policy
+		attempt int
+		want    time.Duration
+	}{
+		{"first attempt", Policy{BaseDelay: time.Second, MaxDelay: time.Minute}, 0, time.Second},
+		{"doubles", Policy{BaseDelay: time.Second, MaxDelay: time.Minute}, 3, 8 * time.Second},
+		{"capped", Policy{BaseDelay: time.Second, MaxDelay: 30 * time.Second}, 5, 30 * time.Second},
+		{"overflow is capped", Policy{BaseDelay: time.Second, MaxDelay: 30 * time.Second}, 40, 30 * time.Second},
+		{"past the top bit", Policy{BaseDelay: time.Second, MaxDelay: 30 * time.Second}, 70, 30 * time.Second},
+		{"zero base stays zero", Policy{BaseDelay: 0, MaxDelay: 30 * time.Second}, 4, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.policy.backoff(tc.attempt); got != tc.want {
+				t.Fatalf("backoff(%d) = %v, want %v", tc.attempt, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	p := Policy{BaseDelay: time.Second, MaxDelay: 30 * time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		got := p.backoff(1)
+		if got < 2*time.Second || got > 3*time.Second {
+			t.Fatalf("backoff(1) = %v, want within [2s, 3s]", got)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"missing", "", 0, false},
+		{"seconds", "7", 7 * time.Second, true},
+		{"past date", "Mon, 02 Jan 2006 15:04:05 GMT", 0, true},
+		{"garbage", "soon", 0, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tc.header != "" {
+				resp.Header.Set("Retry-After", tc.header)
+			}
+			got, ok := retryAfter(resp)
+			if got != tc.want || ok != tc.ok {
+				t.Fatalf("retryAfter = (%v, %v), want (%v, %v)", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if got, ok := retryAfter(resp); !ok || got < 59*time.Minute || got > time.Hour {
+		t.Fatalf("retryAfter(future date) = (%v, %v), want about an hour", got, ok)
+	}
+}
+
+// status builds a response with the given code and Retry-After header.
+func status(code int, retryAfter string) *http.Response {
+	resp := &http.Response{StatusCode: code, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}
+	if retryAfter != "" {
+		resp.Header.Set("Retry-After", retryAfter)
+	}
+	return resp
+}
+
+func TestDoHonoursRetryAfter(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		policy     Policy
+		retryAfter string
+	}{
+		// The backoff alone would wait an hour; Retry-After: 0 replaces it.
+		{"replaces backoff", Policy{MaxAttempts: 2, BaseDelay: time.Hour, MaxDelay: time.Hour}, "0"},
+		// An hour-long Retry-After is still capped at MaxDelay.
+		{"capped at max delay", Policy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}, "3600"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			calls := 0
+			resp, err := tc.policy.Do(ctx, func(ctx context.Context) (*http.Response, error) {
+				calls++
+				if calls == 1 {
+					return status(http.StatusTooManyRequests, tc.retryAfter), nil
+				}
+				return status(http.StatusOK, ""), nil
+			})
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			if resp.StatusCode != http.StatusOK || calls != 2 {
+				t.Fatalf("status = %d after %d calls, want 200 after 2", resp.StatusCode, calls)
+			}
+		})
+	}
+}
+
+func TestDoStopsWhenContextIsDone(t *testing.T) {
+	p := Policy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+	start := time.Now()
+	_, err := p.Do(ctx, func(ctx context.Context) (*http.Response, error) {
+		calls++
+		cancel()
+		return status(http.StatusServiceUnavailable, ""), nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Do waited %v after cancellation", elapsed)
+	}
+}
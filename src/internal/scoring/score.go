@@ -0,0 +1,74 @@
+package scoring
+
+import (
+	"math"
+	"os"
+	"strconv"
+)
+
+// Weights sets how much each outcome contributes to a recomputed score.
+// Weights are relative; they don't need to add up to 1.
+type Weights struct {
+	Completed float64 `json:"completed"`
+	NoShow    float64 `json:"no_show"`
+	Cancelled float64 `json:"cancelled"`
+	Rating    float64 `json:"rating"`
+}
+
+// WeightsFromEnv reads SCORE_WEIGHT_COMPLETED, SCORE_WEIGHT_NO_SHOW,
+// SCORE_WEIGHT_CANCELLED and SCORE_WEIGHT_RATING.
+func WeightsFromEnv() Weights {
+	return Weights{
+		Completed: envWeight("SCORE_WEIGHT_COMPLETED", 0.4),
+		NoShow:    envWeight("SCORE_WEIGHT_NO_SHOW", 0.3),
+		Cancelled: envWeight("SCORE_WEIGHT_CANCELLED", 0.1),
+		Rating:    envWeight("SCORE_WEIGHT_RATING", 0.2),
+	}
+}
+
+func envWeight(name string, def float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil && v >= 0 {
+		return v
+	}
+	return def
+}
+
+// Outcomes are a coach's finished appointments over the scoring window.
+type Outcomes struct {
+	Completed int `json:"completed"`
+	NoShow    int `json:"no_show"`
+	Cancelled int `json:"cancelled"`
+	// AvgRating is the mean customer rating (1-5), nil without ratings.
+	AvgRating *float64 `json:"avg_rating,omitempty"`
+}
+
+func (o Outcomes) Total() int {
+	return o.Completed + o.NoShow + o.Cancelled
+}
+
+// Compute returns a score in [0, 1]: the weighted mean of the completion
+// rate, the no-show and cancellation rates (inverted, so fewer is better) and
+// the normalised rating. The rating term is left out when there are no
+// ratings. Compute returns false if there is nothing to score.
+func Compute(o Outcomes, w Weights) (float64, bool) {
+	total := float64(o.Total())
+	if total == 0 {
+		return 0, false
+	}
+
+	sum := w.Completed*float64(o.Completed)/total +
+		w.NoShow*(1-float64(o.NoShow)/total) +
+		w.Cancelled*(1-float64(o.Cancelled)/total)
+	weight := w.Completed + w.NoShow + w.Cancelled
+
+	if o.AvgRating != nil {
+		sum += w.Rating * (*o.AvgRating - 1) / 4
+		weight += w.Rating
+	}
+	if weight == 0 {
+		return 0, false
+	}
+
+	score := math.Min(math.Max(sum/weight, 0), 1)
+	return math.Round(score*1000) / 1000, true
+}
@@ -0,0 +1,52 @@
+package scoring
+
+import "testing"
+
+func rating(r float64) *float64 {
+	return &r
+}
+
+func TestCompute(t *testing.T) {
+	defaults := Weights{Completed: 0.4, NoShow: 0.3, Cancelled: 0.1, Rating: 0.2}
+
+	for _, tc := range []struct {
+		name     string
+		outcomes Outcomes
+		weights  Weights
+		want     float64
+		ok       bool
+	}{
+		{"no outcomes", Outcomes{AvgRating: rating(5)}, defaults, 0, false},
+		{"all completed", Outcomes{Completed: 10}, defaults, 1, true},
+		{"all no-shows", Outcomes{NoShow: 4}, defaults, 0.125, true},
+		{"all cancelled", Outcomes{Cancelled: 4}, defaults, 0.375, true},
+		{"mixed", Outcomes{Completed: 6, NoShow: 2, Cancelled: 2}, defaults, 0.7, true},
+		{"mixed with rating", Outcomes{Completed: 6, NoShow: 2, Cancelled: 2, AvgRating: rating(3)}, defaults, 0.66, true},
+		{"top rating", Outcomes{Completed: 10, AvgRating: rating(5)}, defaults, 1, true},
+		{"lowest rating", Outcomes{Completed: 10, AvgRating: rating(1)}, defaults, 0.8, true},
+		{"rounded to three places", Outcomes{Completed: 1, NoShow: 2}, defaults, 0.417, true},
+		{"zero weights", Outcomes{Completed: 3}, Weights{}, 0, false},
+		{"only rating weight without ratings", Outcomes{Completed: 3}, Weights{Rating: 1}, 0, false},
+		{"only rating weight", Outcomes{Completed: 3, AvgRating: rating(4)}, Weights{Rating: 1}, 0.75, true},
+		{"weights need not add up to one", Outcomes{Completed: 6, NoShow: 2, Cancelled: 2}, Weights{Completed: 4, NoShow: 3, Cancelled: 1, Rating: 2}, 0.7, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := Compute(tc.outcomes, tc.weights)
+			if ok != tc.ok || got != tc.want {
+				t.Fatalf("Compute = (%v, %v), want (%v, %v)", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestWeightsFromEnv(t *testing.T) {
+	t.Setenv("SCORE_WEIGHT_COMPLETED", "0.5")
+	t.Setenv("SCORE_WEIGHT_NO_SHOW", "-1")
+	t.Setenv("SCORE_WEIGHT_CANCELLED", "not a number")
+	t.Setenv("SCORE_WEIGHT_RATING", "0")
+
+	want := Weights{Completed: 0.5, NoShow: 0.3, Cancelled: 0.1, Rating: 0}
+	if got := WeightsFromEnv(); got != want {
+		t.Fatalf("WeightsFromEnv = %+v, want %+v", got, want)
+	}
+}
@@ -1,17 +1,24 @@
 package server
 
 import (
+	"context"
 	"database/sql"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/httprate"
+	"github.com/transistxr/coach-assignment-server/src/internal/audit"
+	"github.com/transistxr/coach-assignment-server/src/internal/auth"
+	"github.com/transistxr/coach-assignment-server/src/internal/breaker"
 	"github.com/transistxr/coach-assignment-server/src/internal/clients"
 	"github.com/transistxr/coach-assignment-server/src/internal/db"
+	"github.com/transistxr/coach-assignment-server/src/internal/events"
 	"github.com/transistxr/coach-assignment-server/src/internal/handlers"
-	"log"
+	"github.com/transistxr/coach-assignment-server/src/internal/jobs"
+	"github.com/transistxr/coach-assignment-server/src/internal/notify"
+	"github.com/transistxr/coach-assignment-server/src/internal/ratelimit"
+	"github.com/transistxr/coach-assignment-server/src/internal/retry"
+	"github.com/transistxr/coach-assignment-server/src/internal/signing"
 	"net/http"
 	"os"
-	"strconv"
 	"time"
 )
 
@@ -19,32 +26,44 @@ type Server struct {
 	router             *chi.Mux
 	DB                 *sql.DB
 	AvailabilityClient *clients.AvailabilityClient
+	crmSyncer          *jobs.CRMSyncer
+	calendarSyncer     *jobs.CalendarSyncer
+	reassigner         *jobs.Reassigner
+	scoreRecomputer    *jobs.ScoreRecomputer
+	outcomeRecorder    *jobs.OutcomeRecorder
+	reminders          *jobs.ReminderScheduler
+	deliveries         *jobs.DeliveryRedriver
 }
 
 func New(sqlDB *sql.DB, rdb *db.RedisClient) *Server {
 	r := chi.NewRouter()
 
-	rateLimitString := db.GetValueString(rdb, "PROD_ENV_RATE_LIMIT")
-
-	rateLimit, err := strconv.Atoi(rateLimitString)
-	if err != nil {
-		log.Printf("Invalid Rate Limit in Configuration: %s \n", rateLimitString)
-	}
+	limiter := ratelimit.NewLimiterFromEnv(rdb)
 
+	r.Use(middleware.RequestID)
 	r.Use(middleware.Timeout(60 * time.Second))
-	r.Use(httprate.Limit(
-		rateLimit,
-		time.Minute,
-		httprate.WithResponseHeaders(httprate.ResponseHeaders{
-			Limit:     "X-RateLimit-Limit",
-			Remaining: "X-RateLimit-Remaining",
-			Reset:     "X-RateLimit-Reset",
-		}),
-	))
-
-	availabilityClient := clients.NewAvailabilityClient(os.Getenv("CALENDAR_API_URL"))
-	crmClient := clients.NewCRMClient(os.Getenv("CRM_WEBHOOK_URL"))
-	authClient := clients.NewAuthClient(os.Getenv("AUTH_SERVICE_URL"))
+
+	retryPolicy := retry.PolicyFromEnv()
+
+	calendarBreaker := breaker.FromEnv("calendar")
+	crmBreaker := breaker.FromEnv("crm")
+	authBreaker := breaker.FromEnv("auth")
+
+	availabilityClient := clients.NewAvailabilityClient(os.Getenv("CALENDAR_API_URL"), retryPolicy, calendarBreaker)
+	crmClient := clients.NewCRMClient(os.Getenv("CRM_WEBHOOK_URL"), retryPolicy, crmBreaker)
+	authClient := clients.NewAuthClient(os.Getenv("AUTH_SERVICE_URL"), retryPolicy, authBreaker)
+	keyStore := auth.NewKeyStoreFromEnv(sqlDB, auth.NewCacheFromEnv(rdb), authClient)
+	authClient.Invalidator = keyStore
+	webhookVerifier := signing.NewVerifierFromEnv("calendar")
+	publisher := events.NewPublisher(sqlDB, clients.NewSubscriberClient(retryPolicy))
+	crmSyncer := jobs.NewCRMSyncer(sqlDB, crmClient)
+	calendarSyncer := jobs.NewCalendarSyncer(sqlDB, availabilityClient)
+	auditLogger := audit.NewLogger(sqlDB)
+	reassigner := jobs.NewReassigner(sqlDB, crmSyncer, calendarSyncer, auditLogger, publisher)
+	scoreRecomputer := jobs.NewScoreRecomputer(sqlDB, auditLogger)
+	outcomeRecorder := jobs.NewOutcomeRecorder(sqlDB, auditLogger, publisher)
+	reminders := jobs.NewReminderScheduler(sqlDB, notify.NewFromEnv())
+	deliveryRedriver := jobs.NewDeliveryRedriver(sqlDB, publisher)
 
 	deps := &handlers.HandlerDeps{
 		DB:                 sqlDB,
@@ -52,21 +71,126 @@ func New(sqlDB *sql.DB, rdb *db.RedisClient) *Server {
 		AvailabilityClient: availabilityClient,
 		CRMClient:          crmClient,
 		AuthClient:         authClient,
+		KeyStore:           keyStore,
+		Audit:              auditLogger,
+		WebhookVerifier:    webhookVerifier,
+		Publisher:          publisher,
+		CRMSyncer:          crmSyncer,
+		CalendarSyncer:     calendarSyncer,
+		Reassigner:         reassigner,
+		Reminders:          reminders,
 	}
 
-	schedulingHandler := &handlers.SchedulingHandler{Deps: deps}
+	schedulingHandler := handlers.NewSchedulingHandler(deps)
+	subscriptionHandler := &handlers.SubscriptionHandler{Deps: deps}
+	coachHandler := &handlers.CoachHandler{Deps: deps}
+	calendarHandler := &handlers.CalendarHandler{Deps: deps}
+	reassignmentHandler := &handlers.ReassignmentHandler{Deps: deps}
+	keyHandler := handlers.NewKeyHandler(deps)
+	feedbackHandler := handlers.NewFeedbackHandler(deps)
+	auditHandler := &handlers.AuditHandler{Deps: deps}
+	healthHandler := &handlers.HealthHandler{Breakers: []*breaker.Breaker{calendarBreaker, crmBreaker, authBreaker}}
+
+	r.Get("/health", healthHandler.HealthCheck)
+
+	// Calendar webhooks authenticate with X-Signature instead of an API key.
+	r.With(limiter.IPMiddleware).Post("/api/webhooks/calendar", schedulingHandler.WebhookHandler)
+
+	// Feedback links are signed for one appointment and need no API key.
+	r.With(limiter.IPMiddleware).Get("/api/feedback/{token}", feedbackHandler.GetForm)
+	r.With(limiter.IPMiddleware).Post("/api/feedback/{token}", feedbackHandler.Submit)
 
-	r.Get("/health", handlers.HealthCheck)
+	r.Group(func(r chi.Router) {
+		// Only requests without a valid key count against their IP.
+		r.Use(limiter.FailedAuthMiddleware(keyStore.Authenticate))
+		r.Use(limiter.Middleware)
 
-	r.Get("/api/availability", schedulingHandler.GetAvailability)
-	r.Post("/api/appointments", schedulingHandler.BookAppointment)
+		r.With(auth.RequirePermission(auth.PermissionRead)).Get("/api/availability", schedulingHandler.GetAvailability)
+		r.With(auth.RequirePermission(auth.PermissionWrite)).Post("/api/appointments", schedulingHandler.BookAppointment)
+		r.With(auth.RequirePermission(auth.PermissionDelete)).Delete("/api/appointments/{id}", schedulingHandler.CancelAppointment)
+		r.With(auth.RequirePermission(auth.PermissionWrite)).Put("/api/appointments/{id}/outcome", schedulingHandler.RecordOutcome)
+		r.With(auth.RequirePermission(auth.PermissionWrite)).Post("/api/appointments/{id}/feedback-link", feedbackHandler.CreateLink)
+		r.With(auth.RequirePermission(auth.PermissionRead)).Get("/api/appointments/{id}/reminders", schedulingHandler.ListReminders)
 
-	r.Post("/api/webhooks/calendar", schedulingHandler.WebhookHandler)
-	r.Get("/api/coaches/distribution", schedulingHandler.GetCoachDistribution)
+		r.Route("/api/coaches", func(r chi.Router) {
+			read := auth.RequirePermission(auth.PermissionRead)
+			admin := auth.RequirePermission(auth.PermissionAdmin)
+			r.With(read).Get("/distribution", schedulingHandler.GetCoachDistribution)
+			r.With(read).Get("/", coachHandler.ListCoaches)
+			r.With(read).Get("/{id}", coachHandler.GetCoach)
+			r.With(admin).Post("/", coachHandler.CreateCoach)
+			r.With(admin).Patch("/{id}", coachHandler.UpdateCoach)
+			r.With(admin).Delete("/{id}", coachHandler.DeactivateCoach)
+			r.With(read).Get("/{id}/score/history", coachHandler.ScoreHistory)
+			r.With(read).Get("/{id}/feedback", feedbackHandler.CoachFeedback)
+			r.With(admin).Put("/{id}/score", coachHandler.SetScore)
+			r.With(read).Get("/{id}/time-off", coachHandler.ListTimeOff)
+			r.With(admin).Post("/{id}/time-off", coachHandler.CreateTimeOff)
+			r.With(admin).Delete("/{id}/time-off/{timeOffId}", coachHandler.DeleteTimeOff)
+		})
 
-	return &Server{router: r}
+		r.Route("/api/calendars", func(r chi.Router) {
+			read := auth.RequirePermission(auth.PermissionRead)
+			admin := auth.RequirePermission(auth.PermissionAdmin)
+			r.With(read).Get("/", calendarHandler.ListCalendars)
+			r.With(read).Get("/{id}", calendarHandler.GetCalendar)
+			r.With(admin).Post("/", calendarHandler.CreateCalendar)
+			r.With(admin).Patch("/{id}", calendarHandler.UpdateCalendar)
+			r.With(admin).Delete("/{id}", calendarHandler.DeactivateCalendar)
+			r.With(admin).Put("/{id}/coaches/{coachId}", calendarHandler.AddCoach)
+			r.With(admin).Delete("/{id}/coaches/{coachId}", calendarHandler.RemoveCoach)
+		})
+
+		r.Route("/api/subscriptions", func(r chi.Router) {
+			r.Use(auth.RequirePermission(auth.PermissionAdmin))
+			r.Post("/", subscriptionHandler.CreateSubscription)
+			r.Get("/", subscriptionHandler.ListSubscriptions)
+			r.Delete("/{id}", subscriptionHandler.DeleteSubscription)
+			r.Get("/{id}/deliveries", subscriptionHandler.ListDeliveries)
+		})
+
+		r.Route("/api/keys", func(r chi.Router) {
+			r.Use(auth.RequirePermission(auth.PermissionAdmin))
+			r.Post("/", keyHandler.CreateKey)
+			r.Get("/", keyHandler.ListKeys)
+			r.Delete("/{id}", keyHandler.DeactivateKey)
+			r.Post("/{id}/rotate", keyHandler.RotateKey)
+		})
+
+		r.Route("/api/admin/coaches", func(r chi.Router) {
+			r.Use(auth.RequirePermission(auth.PermissionAdmin))
+			r.Post("/import", coachHandler.ImportCoaches)
+			r.Get("/export", coachHandler.ExportCoaches)
+		})
+
+		r.Route("/api/reassignments", func(r chi.Router) {
+			r.Use(auth.RequirePermission(auth.PermissionAdmin))
+			r.Get("/", reassignmentHandler.ListPending)
+			r.Post("/run", reassignmentHandler.Run)
+		})
+
+		r.With(auth.RequirePermission(auth.PermissionAdmin)).Get("/api/audit", auditHandler.ListAudit)
+	})
+
+	return &Server{
+		router:          r,
+		crmSyncer:       crmSyncer,
+		calendarSyncer:  calendarSyncer,
+		reassigner:      reassigner,
+		scoreRecomputer: scoreRecomputer,
+		outcomeRecorder: outcomeRecorder,
+		reminders:       reminders,
+		deliveries:      deliveryRedriver,
+	}
 }
 
 func (s *Server) Start(addr string) error {
+	go s.crmSyncer.Run(context.Background())
+	go s.calendarSyncer.Run(context.Background())
+	go s.reassigner.Run(context.Background())
+	go s.scoreRecomputer.Run(context.Background())
+	go s.outcomeRecorder.Run(context.Background())
+	go s.reminders.Run(context.Background())
+	go s.deliveries.Run(context.Background())
 	return http.ListenAndServe(addr, s.router)
 }
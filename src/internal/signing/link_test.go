@@ -0,0 +1,94 @@
+package signing
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fixedSigner(secret string, ttl time.Duration, now *time.Time) *LinkSigner {
+	s := NewLinkSigner(secret, ttl)
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func TestLinkSignerRoundTrip(t *testing.T) {
+	now := time.Date(2024, 2, 1, 10, 0, 0, 500, time.UTC)
+	s := fixedSigner("secret", time.Hour, &now)
+
+	for _, subject := range []string{"apt-123", "feedback.apt-123.v2", ""} {
+		token, expiresAt, err := s.Sign(subject)
+		if err != nil {
+			t.Fatalf("Sign(%q): %v", subject, err)
+		}
+		if want := time.Date(2024, 2, 1, 11, 0, 0, 0, time.UTC); !expiresAt.Equal(want) {
+			t.Errorf("Sign(%q) expires at %v, want %v", subject, expiresAt, want)
+		}
+		if strings.ContainsAny(token, "+/=") {
+			t.Errorf("Sign(%q) = %q, not URL-safe", subject, token)
+		}
+		got, err := s.Verify(token)
+		if err != nil || got != subject {
+			t.Errorf("Verify(Sign(%q)) = (%q, %v)", subject, got, err)
+		}
+	}
+}
+
+func TestLinkSignerVerify(t *testing.T) {
+	issued := time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC)
+	now := issued
+	s := fixedSigner("secret", time.Hour, &now)
+	token, _, err := s.Sign("apt-123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, signature, _ := strings.Cut(token, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte("apt-456." + strings.Split(mustDecode(t, encoded), ".")[1]))
+
+	for _, tc := range []struct {
+		name   string
+		signer *LinkSigner
+		token  string
+		at     time.Time
+		want   error
+	}{
+		{"valid", s, token, issued.Add(30 * time.Minute), nil},
+		{"at expiry", s, token, issued.Add(time.Hour), nil},
+		{"expired", s, token, issued.Add(time.Hour + time.Second), ErrExpiredToken},
+		{"other secret", fixedSigner("other", time.Hour, &now), token, issued, ErrInvalidToken},
+		{"no secret", fixedSigner("", time.Hour, &now), token, issued, ErrNoLinkSecret},
+		{"tampered signature", s, encoded + "." + strings.Repeat("0", len(signature)), issued, ErrInvalidToken},
+		{"tampered subject", s, forged + "." + signature, issued, ErrInvalidToken},
+		{"missing signature", s, encoded, issued, ErrInvalidToken},
+		{"bad encoding", s, "!!!." + signature, issued, ErrInvalidToken},
+		{"empty", s, "", issued, ErrInvalidToken},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			now = tc.at
+			subject, err := tc.signer.Verify(tc.token)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("Verify err = %v, want %v", err, tc.want)
+			}
+			if tc.want == nil && subject != "apt-123" {
+				t.Fatalf("Verify subject = %q, want %q", subject, "apt-123")
+			}
+		})
+	}
+}
+
+func TestLinkSignerWithoutSecret(t *testing.T) {
+	if _, _, err := NewLinkSigner("", time.Hour).Sign("apt-123"); !errors.Is(err, ErrNoLinkSecret) {
+		t.Fatalf("Sign err = %v, want %v", err, ErrNoLinkSecret)
+	}
+}
+
+func mustDecode(t *testing.T, s string) string {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
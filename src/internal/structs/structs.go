@@ -1,6 +1,7 @@
 package structs
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -25,6 +26,20 @@ type AvailabilityResponse struct {
 	BaseResponse
 	Slots          []AvailabilitySlot    `json:"slots"`
 	TotalAvailable int       `json:"total_available"`
+	Degraded       bool      `json:"degraded,omitempty"` // served from cached slots, calendar API unavailable
+	// GroupSessions are booked group sessions that still have seats.
+	GroupSessions []GroupSession `json:"group_sessions,omitempty"`
+}
+
+// GroupSession is a coach's session on a group calendar and its seats.
+type GroupSession struct {
+	CoachID        string    `json:"coach_id"`
+	CalendarID     string    `json:"calendar_id"`
+	StartTime      time.Time `json:"start_time"`
+	EndTime        time.Time `json:"end_time"`
+	Capacity       int       `json:"capacity"`
+	SeatsTaken     int       `json:"seats_taken"`
+	SeatsRemaining int       `json:"seats_remaining"`
 }
 
 type BlockSlotRequest struct {
@@ -86,9 +101,48 @@ type AvailabilitySlot struct {
 }
 
 
+// Calendar webhook event types understood by WebhookHandler.
+const (
+	EventAppointmentCancelled   = "appointment.cancelled"
+	EventAppointmentConfirmed   = "appointment.confirmed"
+	EventAppointmentRescheduled = "appointment.rescheduled"
+	EventSlotBlocked            = "slot.blocked"
+	EventSlotReleased           = "slot.released"
+	EventCoachSettingsChanged   = "coach.settings_changed"
+)
+
+// WebHookRequest is the envelope of every calendar webhook. Data holds the
+// event-specific payload and is decoded once EventType is known. A top-level
+// appointment_id is still accepted for appointment events.
 type WebHookRequest struct {
-	EventType     string `json:"event_type"`
+	EventID       string          `json:"event_id,omitempty"`
+	EventType     string          `json:"event_type"`
+	OccurredAt    *time.Time      `json:"occurred_at,omitempty"`
+	AppointmentID string          `json:"appointment_id,omitempty"`
+	Data          json.RawMessage `json:"data,omitempty"`
+}
+
+type AppointmentEventData struct {
 	AppointmentID string `json:"appointment_id"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+type AppointmentRescheduledData struct {
+	AppointmentID string    `json:"appointment_id"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+}
+
+type SlotEventData struct {
+	CoachID   string    `json:"coach_id"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+type CoachSettingsChangedData struct {
+	CoachID              string        `json:"coach_id"`
+	WorkingHours         *WorkingHours `json:"working_hours,omitempty"`
+	MaxDailyAppointments *int          `json:"max_daily_appointments,omitempty"`
 }
 
 type WebHookResponse struct {
@@ -100,15 +154,36 @@ type WebHookResponse struct {
 type CoachDistribution struct {
 	CoachID           string  `json:"coach_id"`
 	Name              string  `json:"name"`
-	Email             string  `json:email`
-	Score             float64 `json:score`
+	Email             string  `json:"email"`
+	Score             float64 `json:"score"`
 	AppointmentsCount int     `json:"appointments_count"`
 	Utilization       float64 `json:"utilization"`
+	OutcomeStats
+}
+
+// OutcomeStats counts the outcomes and customer ratings of appointments that
+// started within the report window. Rates are shares of completed plus
+// no-show appointments.
+type OutcomeStats struct {
+	CompletedCount int      `json:"completed_count"`
+	NoShowCount    int      `json:"no_show_count"`
+	CompletionRate float64  `json:"completion_rate"`
+	NoShowRate     float64  `json:"no_show_rate"`
+	RatingCount    int      `json:"rating_count"`
+	AvgRating      *float64 `json:"avg_rating,omitempty"`
+}
+
+type CalendarDistribution struct {
+	CalendarID string `json:"calendar_id"`
+	Name       string `json:"name"`
+	OutcomeStats
 }
 
 type CoachDistributionResponse struct {
-	Distribution  []CoachDistribution `json:"distribution"`
-	FairnessScore float64             `json:"fairness_score"`
+	Distribution      []CoachDistribution    `json:"distribution"`
+	Calendars         []CalendarDistribution `json:"calendars"`
+	FairnessScore     float64                `json:"fairness_score"`
+	OutcomeWindowDays int                    `json:"outcome_window_days"`
 }
 
 type BookAppointmentRequest struct {
@@ -118,6 +193,9 @@ type BookAppointmentRequest struct {
 	StartTime    time.Time `json:"start_time"`
 	TimeZone     string    `json:"timezone"`
 	Notes        string    `json:"notes"`
+
+	// Recurrence books a weekly series, e.g. "FREQ=WEEKLY;INTERVAL=2;COUNT=6".
+	Recurrence string `json:"recurrence,omitempty"`
 }
 
 type BookAppointmentResponse struct {
@@ -125,8 +203,104 @@ type BookAppointmentResponse struct {
 	AppointmentID string    `json:"appointment_id"`
 	CoachID       string    `json:"coach_id"`
 	StartTime     time.Time `json:"start_time"`
-	EndTime       time.Time `json:end_time`
+	EndTime       time.Time `json:"end_time"`
 	Status        string    `json:"status"`
+
+	// Set for recurring bookings only.
+	SeriesID    string               `json:"series_id,omitempty"`
+	Occurrences []SeriesOccurrence   `json:"occurrences,omitempty"`
+	Unplaced    []UnplacedOccurrence `json:"unplaced,omitempty"`
+}
+
+type SeriesOccurrence struct {
+	Index         int       `json:"index"`
+	AppointmentID string    `json:"appointment_id"`
+	CoachID       string    `json:"coach_id"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+}
+
+// UnplacedOccurrence is an occurrence of a series no coach could take.
+type UnplacedOccurrence struct {
+	Index     int       `json:"index"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Reason    string    `json:"reason"`
+}
+
+type CancelAppointmentResponse struct {
+	BaseResponse
+	AppointmentID string `json:"appointment_id"`
+	Status        string `json:"status"`
+
+	// CancelledAppointments lists every appointment cancelled with
+	// scope=following, the requested one first.
+	CancelledAppointments []string `json:"cancelled_appointments,omitempty"`
+}
+
+type OutcomeRequest struct {
+	Status string `json:"status"`
+}
+
+type OutcomeResponse struct {
+	AppointmentID     string     `json:"appointment_id"`
+	Status            string     `json:"status"`
+	OutcomeRecordedAt *time.Time `json:"outcome_recorded_at,omitempty"`
+}
+
+type Reminder struct {
+	ID            string     `json:"id"`
+	OffsetMinutes int        `json:"offset_minutes"`
+	SendAt        time.Time  `json:"send_at"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
+
+type ReminderListResponse struct {
+	AppointmentID string     `json:"appointment_id"`
+	Reminders     []Reminder `json:"reminders"`
+}
+
+type FeedbackLinkResponse struct {
+	AppointmentID string    `json:"appointment_id"`
+	URL           string    `json:"url"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// FeedbackForm is what a feedback link shows before it is used.
+type FeedbackForm struct {
+	AppointmentID string    `json:"appointment_id"`
+	CoachName     string    `json:"coach_name"`
+	CalendarName  string    `json:"calendar_name"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	Submitted     bool      `json:"submitted"`
+}
+
+type FeedbackRequest struct {
+	Rating  int    `json:"rating"`
+	Comment string `json:"comment"`
+}
+
+type Feedback struct {
+	ID            string    `json:"id"`
+	AppointmentID string    `json:"appointment_id"`
+	CoachID       string    `json:"coach_id"`
+	Rating        int       `json:"rating"`
+	Comment       string    `json:"comment,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// CoachFeedbackResponse aggregates a coach's ratings; Ratings counts each
+// star value ("1" to "5") and Feedback lists the most recent entries.
+type CoachFeedbackResponse struct {
+	CoachID     string         `json:"coach_id"`
+	RatingCount int            `json:"rating_count"`
+	AvgRating   *float64       `json:"avg_rating,omitempty"`
+	Ratings     map[string]int `json:"ratings"`
+	Feedback    []Feedback     `json:"feedback"`
 }
 
 type Coach struct {
@@ -142,6 +316,195 @@ type Coach struct {
 
 
 
+// CoachRequest creates or updates a coach; omitted fields keep their current
+// (or default) values.
+type CoachRequest struct {
+	Name                 *string       `json:"name,omitempty"`
+	Email                *string       `json:"email,omitempty"`
+	Score                *float64      `json:"score,omitempty"`
+	MaxDailyAppointments *int          `json:"max_daily_appointments,omitempty"`
+	WorkingHours         *WorkingHours `json:"working_hours,omitempty"`
+	Active               *bool         `json:"active,omitempty"`
+}
+
+type CoachProfile struct {
+	ID                   string       `json:"id"`
+	Name                 string       `json:"name"`
+	Email                string       `json:"email"`
+	Score                float64      `json:"score"`
+	ScoreLocked          bool         `json:"score_locked"`
+	MaxDailyAppointments int          `json:"max_daily_appointments"`
+	WorkingHours         WorkingHours `json:"working_hours"`
+	Active               bool         `json:"active"`
+	CreatedAt            time.Time    `json:"created_at"`
+	UpdatedAt            *time.Time   `json:"updated_at,omitempty"`
+}
+
+type CoachResponse struct {
+	BaseResponse
+	CoachProfile
+}
+
+type CoachListResponse struct {
+	BaseResponse
+	Coaches []CoachProfile `json:"coaches"`
+}
+
+// TimeOffRequest records a coach's unavailability: either start_time and
+// end_time, or whole days from start_date to end_date (inclusive, YYYY-MM-DD
+// in the coach's timezone).
+type TimeOffRequest struct {
+	StartTime *time.Time `json:"start_time,omitempty"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+	StartDate string     `json:"start_date,omitempty"`
+	EndDate   string     `json:"end_date,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+type TimeOff struct {
+	ID        string    `json:"id"`
+	CoachID   string    `json:"coach_id"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	AllDay    bool      `json:"all_day"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TimeOffResponse struct {
+	BaseResponse
+	TimeOff
+	// FlaggedAppointments are scheduled appointments in the period, now
+	// marked as needing reassignment.
+	FlaggedAppointments []string `json:"flagged_appointments,omitempty"`
+}
+
+type TimeOffListResponse struct {
+	BaseResponse
+	TimeOff []TimeOff `json:"time_off"`
+}
+
+// ScoreUpdateRequest sets a coach's score by hand. Locked keeps the
+// recompute job from overwriting it; omit it to leave the lock as is.
+type ScoreUpdateRequest struct {
+	Score  *float64 `json:"score,omitempty"`
+	Locked *bool    `json:"locked,omitempty"`
+	Reason string   `json:"reason,omitempty"`
+}
+
+type ScoreHistoryEntry struct {
+	ID            string          `json:"id"`
+	Score         float64         `json:"score"`
+	PreviousScore *float64        `json:"previous_score,omitempty"`
+	Source        string          `json:"source"`
+	Reason        string          `json:"reason,omitempty"`
+	Details       json.RawMessage `json:"details,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+type ScoreHistoryResponse struct {
+	BaseResponse
+	CoachID     string              `json:"coach_id"`
+	Score       float64             `json:"score"`
+	ScoreLocked bool                `json:"score_locked"`
+	History     []ScoreHistoryEntry `json:"history"`
+}
+
+// CoachImportRow is the outcome for one CSV line; Line counts the header as
+// line 1.
+type CoachImportRow struct {
+	Line   int      `json:"line"`
+	Email  string   `json:"email"`
+	Action string   `json:"action,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+type CoachImportResponse struct {
+	BaseResponse
+	DryRun  bool             `json:"dry_run"`
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Rows    []CoachImportRow `json:"rows"`
+}
+
+type ReassignedAppointment struct {
+	AppointmentID   string `json:"appointment_id"`
+	PreviousCoachID string `json:"previous_coach_id"`
+	CoachID         string `json:"coach_id"`
+}
+
+// PendingReassignment is a flagged appointment still with its original
+// coach, either not tried yet or with no other coach free.
+type PendingReassignment struct {
+	AppointmentID string     `json:"appointment_id"`
+	CoachID       string     `json:"coach_id"`
+	CalendarID    string     `json:"calendar_id"`
+	StartTime     time.Time  `json:"start_time"`
+	EndTime       time.Time  `json:"end_time"`
+	Reason        string     `json:"reason"`
+	Error         string     `json:"error,omitempty"`
+	AttemptedAt   *time.Time `json:"attempted_at,omitempty"`
+}
+
+type ReassignmentReport struct {
+	BaseResponse
+	Reassigned []ReassignedAppointment `json:"reassigned"`
+	Unplaced   []PendingReassignment   `json:"unplaced"`
+}
+
+type PendingReassignmentsResponse struct {
+	BaseResponse
+	Appointments []PendingReassignment `json:"appointments"`
+}
+
+// CalendarRequest creates or updates a calendar (appointment type); omitted
+// fields keep their current (or default) values.
+type CalendarRequest struct {
+	Name         *string `json:"name,omitempty"`
+	CalendarType *string `json:"calendar_type,omitempty"`
+	SlotDuration *int    `json:"slot_duration,omitempty"`
+	SlotInterval *int    `json:"slot_interval,omitempty"`
+	Active       *bool   `json:"active,omitempty"`
+	// Capacity is the seats per session; only "group" calendars take more
+	// than one.
+	Capacity *int `json:"capacity,omitempty"`
+}
+
+type Calendar struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	CalendarType string     `json:"calendar_type"`
+	SlotDuration int        `json:"slot_duration"`
+	SlotInterval int        `json:"slot_interval"`
+	Capacity     int        `json:"capacity"`
+	Active       bool       `json:"active"`
+	CoachIDs     []string   `json:"coach_ids"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+}
+
+type CalendarResponse struct {
+	BaseResponse
+	Calendar
+	// PreservedAppointments lists future scheduled appointments that keep
+	// their original length after a slot_duration change.
+	PreservedAppointments []string `json:"preserved_appointments,omitempty"`
+}
+
+// CalendarConflictResponse is the 409 for a slot_duration change that future
+// appointments could not be rescheduled to.
+type CalendarConflictResponse struct {
+	BaseResponse
+	// ConflictingAppointments would overlap their coach's next booking or
+	// run past the coach's working hours at the new duration.
+	ConflictingAppointments []string `json:"conflicting_appointments"`
+}
+
+type CalendarListResponse struct {
+	BaseResponse
+	Calendars []Calendar `json:"calendars"`
+}
+
 type ValidateRequest struct {
 	APIKey string `json:"api_key"`
 }
@@ -149,6 +512,10 @@ type ValidateRequest struct {
 type ValidateResponse struct {
 	Valid       bool   `json:"valid"`
 	Error       string `json:"error,omitempty"`
+	KeyID       string `json:"key_id,omitempty"`
+	TenantID    string `json:"tenant_id,omitempty"`
+	RetryAfter  int    `json:"retry_after,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
 	KeyType     string `json:"key_type,omitempty"`
 	RateLimit   *RateLimit `json:"rate_limit,omitempty"`
 	Permissions *Permissions `json:"permissions,omitempty"`
@@ -165,6 +532,7 @@ type Permissions struct {
 	Read   bool `json:"read"`
 	Write  bool `json:"write"`
 	Delete bool `json:"delete"`
+	Admin  bool `json:"admin"`
 }
 
 type KeyInfo struct {
@@ -184,6 +552,60 @@ type RotateKeyResponse struct {
 }
 
 
+type CreateAPIKeyRequest struct {
+	Name        string       `json:"name"`
+	KeyType     string       `json:"key_type"`
+	Permissions *Permissions `json:"permissions,omitempty"`
+	RateLimit   int          `json:"rate_limit,omitempty"`
+}
+
+type RotateAPIKeyRequest struct {
+	GracePeriodSeconds *int `json:"grace_period_seconds,omitempty"`
+}
+
+type APIKey struct {
+	ID          string      `json:"id"`
+	TenantID    string      `json:"tenant_id"`
+	Name        string      `json:"name"`
+	KeyType     string      `json:"key_type"`
+	KeyPrefix   string      `json:"key_prefix,omitempty"`
+	Permissions Permissions `json:"permissions"`
+	RateLimit   int         `json:"rate_limit"`
+	Active      bool        `json:"active"`
+	ExpiresAt   *time.Time  `json:"expires_at,omitempty"`
+	RotatedFrom string      `json:"rotated_from,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	LastUsedAt  *time.Time  `json:"last_used_at,omitempty"`
+}
+
+// APIKeyResponse carries the plaintext Key only when it was just created or
+// rotated; it cannot be retrieved again.
+type APIKeyResponse struct {
+	BaseResponse
+	APIKey
+	Key string `json:"key,omitempty"`
+}
+
+type APIKeyListResponse struct {
+	BaseResponse
+	Keys []APIKey `json:"keys"`
+}
+
+type RotateAPIKeyResponse struct {
+	BaseResponse
+	Key             string    `json:"key"`
+	NewKey          APIKey    `json:"new_key"`
+	OldKeyID        string    `json:"old_key_id"`
+	OldKeyExpiresAt time.Time `json:"old_key_expires_at"`
+}
+
+// SyncKeyRequest mirrors an api_keys row to the auth service. Only the hash
+// is sent, never the key.
+type SyncKeyRequest struct {
+	APIKey
+	KeyHash string `json:"key_hash"`
+}
+
 type AppointmentCreatedRequest struct {
 	AppointmentID string `json:"appointment_id"`
 	CoachID       string `json:"coach_id"`
@@ -200,8 +622,10 @@ type AppointmentCreatedResponse struct {
 }
 
 type AppointmentUpdatedRequest struct {
-	AppointmentID string `json:"appointment_id"`
-	Status        string `json:"status"`
+	AppointmentID   string `json:"appointment_id"`
+	Status          string `json:"status"`
+	CoachID         string `json:"coach_id,omitempty"`
+	PreviousCoachID string `json:"previous_coach_id,omitempty"`
 }
 
 type AppointmentUpdatedResponse struct {
@@ -229,3 +653,125 @@ type Contact struct {
 	Score     float64   `json:"score"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// Outbound events published to webhook subscriptions. Appointment state
+// changes reuse the calendar event names above.
+const (
+	EventAppointmentCreated    = "appointment.created"
+	EventCoachCapacityReached  = "coach.capacity_reached"
+	EventAppointmentReassigned = "appointment.reassigned"
+	EventAppointmentCompleted  = "appointment.completed"
+	EventAppointmentNoShow     = "appointment.no_show"
+)
+
+// SubscribableEvents lists the event types a subscription may ask for.
+var SubscribableEvents = []string{
+	EventAppointmentCreated,
+	EventAppointmentCancelled,
+	EventAppointmentRescheduled,
+	EventAppointmentConfirmed,
+	EventCoachCapacityReached,
+	EventAppointmentReassigned,
+	EventAppointmentCompleted,
+	EventAppointmentNoShow,
+}
+
+type OutboundEvent struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type AppointmentEvent struct {
+	AppointmentID string    `json:"appointment_id"`
+	CoachID       string    `json:"coach_id"`
+	CalendarID    string    `json:"calendar_id"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	Status        string    `json:"status"`
+
+	// PreviousCoachID is only set on appointment.reassigned.
+	PreviousCoachID string `json:"previous_coach_id,omitempty"`
+}
+
+type CoachCapacityEvent struct {
+	CoachID              string `json:"coach_id"`
+	Date                 string `json:"date"`
+	Appointments         int    `json:"appointments"`
+	MaxDailyAppointments int    `json:"max_daily_appointments"`
+}
+
+type DeliveryResult struct {
+	Attempts       int `json:"attempts"`
+	ResponseStatus int `json:"response_status"`
+}
+
+type CreateSubscriptionRequest struct {
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret,omitempty"`
+	EventTypes  []string `json:"event_types"`
+	Description string   `json:"description,omitempty"`
+}
+
+type Subscription struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	EventTypes  []string  `json:"event_types"`
+	Description string    `json:"description,omitempty"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type SubscriptionResponse struct {
+	BaseResponse
+	Subscription *Subscription `json:"subscription,omitempty"`
+}
+
+type SubscriptionListResponse struct {
+	BaseResponse
+	Subscriptions []Subscription `json:"subscriptions"`
+}
+
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	ErrorMessage   string          `json:"error_message,omitempty"`
+	LastAttempt    *time.Time      `json:"last_attempt,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type DeliveryListResponse struct {
+	BaseResponse
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
+
+type AuditEntry struct {
+	ID         string          `json:"id"`
+	ActorType  string          `json:"actor_type"`
+	ActorID    string          `json:"actor_id,omitempty"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type AuditListResponse struct {
+	BaseResponse
+	Entries []AuditEntry `json:"entries"`
+}
+
+type HealthResponse struct {
+	Status   string            `json:"status"`
+	Breakers map[string]string `json:"breakers"`
+}